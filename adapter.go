@@ -0,0 +1,127 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Adapter describes an i2c adapter, as exposed by sysfs.
+type Adapter struct {
+	Number    int    // i2c bus number, as used by OpenFile
+	Name      string // name of the adapter
+	Path      string // sysfs path of the adapter device
+	Parent    string // sysfs path of the parent device (the mux device, for mux channels)
+	MuxParent int    // bus number of the parent adapter for mux channels, or -1
+}
+
+// Adapters returns the list of i2c adapters of the system, sorted by bus number.
+func Adapters() ([]Adapter, error) {
+	return AdaptersAt(sysfs)
+}
+
+// AdaptersAt returns the list of i2c adapters described by the sysfs
+// filesystem mounted at root, sorted by bus number.
+func AdaptersAt(root string) ([]Adapter, error) {
+	dir := filepath.Join(root, "class", "i2c-adapter")
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("smbus: could not list i2c adapters: %w", err)
+	}
+
+	var adps []Adapter
+	for _, ent := range ents {
+		n, ok := busNumber(ent.Name())
+		if !ok {
+			continue
+		}
+		path, err := filepath.EvalSymlinks(filepath.Join(dir, ent.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("smbus: could not resolve i2c adapter %q: %w", ent.Name(), err)
+		}
+		name, err := os.ReadFile(filepath.Join(path, "name"))
+		if err != nil {
+			return nil, fmt.Errorf("smbus: could not read name of i2c adapter %q: %w", ent.Name(), err)
+		}
+
+		adp := Adapter{
+			Number:    n,
+			Name:      strings.TrimSpace(string(name)),
+			Path:      path,
+			Parent:    filepath.Dir(path),
+			MuxParent: -1,
+		}
+		if n, ok := busNumber(filepath.Base(adp.Parent)); ok {
+			adp.MuxParent = n
+			if mux, err := filepath.EvalSymlinks(filepath.Join(path, "mux_device")); err == nil {
+				adp.Parent = mux
+			}
+		}
+		adps = append(adps, adp)
+	}
+
+	sort.Slice(adps, func(i, j int) bool {
+		return adps[i].Number < adps[j].Number
+	})
+	return adps, nil
+}
+
+// busNumber returns the bus number of the "i2c-N" sysfs entry name.
+func busNumber(name string) (int, bool) {
+	if !strings.HasPrefix(name, "i2c-") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(name, "i2c-"))
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// AdapterByName returns the i2c adapter with the provided name.
+// AdapterByName returns an error if no adapter, or more than one adapter,
+// has that name.
+func AdapterByName(name string) (Adapter, error) {
+	adps, err := Adapters()
+	if err != nil {
+		return Adapter{}, err
+	}
+	return findAdapter(adps, name)
+}
+
+func findAdapter(adps []Adapter, name string) (Adapter, error) {
+	var (
+		adp Adapter
+		n   int
+	)
+	for _, v := range adps {
+		if v.Name == name {
+			adp = v
+			n++
+		}
+	}
+	switch n {
+	case 0:
+		return Adapter{}, fmt.Errorf("smbus: no i2c adapter named %q", name)
+	case 1:
+		return adp, nil
+	}
+	return Adapter{}, fmt.Errorf("smbus: %d i2c adapters named %q", n, name)
+}
+
+// OpenByName opens a connection to the i2c adapter with the provided name.
+// Users should call SetAddr afterwards to have a properly configured SMBus connection.
+func OpenByName(name string, opts ...func(cfg *config)) (*Conn, error) {
+	adp, err := AdapterByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return OpenFile(adp.Number, opts...)
+}
@@ -0,0 +1,109 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-daq/smbus"
+)
+
+func TestAdapters(t *testing.T) {
+	root := t.TempDir()
+
+	mkdir := func(dir string) {
+		t.Helper()
+		err := os.MkdirAll(filepath.Join(root, dir), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(fname, content string) {
+		t.Helper()
+		err := os.WriteFile(filepath.Join(root, fname), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	symlink := func(dst, src string) {
+		t.Helper()
+		err := os.Symlink(dst, filepath.Join(root, src))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const (
+		soc = "devices/platform/soc/fe804000.i2c"
+		usb = "devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0"
+	)
+	mkdir("class/i2c-adapter")
+	mkdir(soc + "/i2c-1/1-0070")
+	mkdir(soc + "/i2c-1/i2c-11")
+	mkdir(usb + "/i2c-3")
+	write(soc+"/i2c-1/name", "bcm2835 (i2c@7e804000)\n")
+	write(soc+"/i2c-1/i2c-11/name", "i2c-1-mux (chan_id 0)\n")
+	write(usb+"/i2c-3/name", "i2c-tiny-usb at bus 001 device 004\n")
+	symlink("../1-0070", soc+"/i2c-1/i2c-11/mux_device")
+	symlink("../../"+soc+"/i2c-1", "class/i2c-adapter/i2c-1")
+	symlink("../../"+soc+"/i2c-1/i2c-11", "class/i2c-adapter/i2c-11")
+	symlink("../../"+usb+"/i2c-3", "class/i2c-adapter/i2c-3")
+
+	adps, err := smbus.AdaptersAt(root)
+	if err != nil {
+		t.Fatalf("adapters error: %v", err)
+	}
+
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []smbus.Adapter{
+		{
+			Number:    1,
+			Name:      "bcm2835 (i2c@7e804000)",
+			Path:      filepath.Join(root, soc, "i2c-1"),
+			Parent:    filepath.Join(root, soc),
+			MuxParent: -1,
+		},
+		{
+			Number:    3,
+			Name:      "i2c-tiny-usb at bus 001 device 004",
+			Path:      filepath.Join(root, usb, "i2c-3"),
+			Parent:    filepath.Join(root, usb),
+			MuxParent: -1,
+		},
+		{
+			Number:    11,
+			Name:      "i2c-1-mux (chan_id 0)",
+			Path:      filepath.Join(root, soc, "i2c-1", "i2c-11"),
+			Parent:    filepath.Join(root, soc, "i2c-1", "1-0070"),
+			MuxParent: 1,
+		},
+	}
+	if !reflect.DeepEqual(adps, want) {
+		t.Fatalf("invalid adapters:\ngot= %+v\nwant=%+v", adps, want)
+	}
+
+	adp, err := smbus.FindAdapter(adps, "i2c-tiny-usb at bus 001 device 004")
+	if err != nil {
+		t.Fatalf("find-adapter error: %v", err)
+	}
+	if adp.Number != 3 {
+		t.Fatalf("invalid adapter: got=%d, want=3", adp.Number)
+	}
+
+	_, err = smbus.FindAdapter(adps, "not-there")
+	if err == nil {
+		t.Fatalf("expected an error for a missing adapter")
+	}
+	_, err = smbus.FindAdapter(append(adps, adps[0]), adps[0].Name)
+	if err == nil {
+		t.Fatalf("expected an error for an ambiguous adapter name")
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus
+
+import (
+	"fmt"
+	"syscall"
+)
+
+const i2cTenBit = 0x0704
+
+// Addr is the address of a device on an i2c bus.
+// Addr can hold 7-bit and 10-bit addresses.
+type Addr uint16
+
+const (
+	addrTenBit Addr = 0x8000 // 10-bit address flag
+	addrMask7  Addr = 0x7f
+	addrMask10 Addr = 0x3ff
+)
+
+// Addr7 returns the 7-bit address a.
+func Addr7(a uint8) Addr {
+	return Addr(a)
+}
+
+// Addr10 returns the 10-bit address a.
+func Addr10(a uint16) Addr {
+	return Addr(a) | addrTenBit
+}
+
+// TenBit returns whether a is a 10-bit address.
+func (a Addr) TenBit() bool {
+	return a&addrTenBit != 0
+}
+
+// Value returns the numerical value of the address, without the 10-bit flag.
+func (a Addr) Value() uint16 {
+	return uint16(a &^ addrTenBit)
+}
+
+// Reserved returns whether a is a 7-bit address reserved by the i2c
+// specification: general call, CBUS, other bus formats, future purposes,
+// HS-mode master codes (0x00-0x07) and 10-bit addressing (0x78-0x7f).
+func (a Addr) Reserved() bool {
+	if a.TenBit() {
+		return false
+	}
+	v := a.Value()
+	return v <= 0x07 || (0x78 <= v && v <= 0x7f)
+}
+
+// Validate returns an error if a is out of range or is a reserved 7-bit address.
+func (a Addr) Validate() error {
+	switch {
+	case a.TenBit() && a.Value() > uint16(addrMask10):
+		return fmt.Errorf("smbus: invalid 10-bit address %v", a)
+	case !a.TenBit() && a.Value() > uint16(addrMask7):
+		return fmt.Errorf("smbus: invalid 7-bit address %v", a)
+	case a.Reserved():
+		return fmt.Errorf("smbus: reserved 7-bit address %v", a)
+	}
+	return nil
+}
+
+func (a Addr) String() string {
+	if a.TenBit() {
+		return fmt.Sprintf("0x%03x(10-bit)", a.Value())
+	}
+	return fmt.Sprintf("0x%02x", a.Value())
+}
+
+// wire returns the address byte(s) sent on the bus for a transfer
+// in the rw direction (i2cSMBusRead or i2cSMBusWrite).
+func (a Addr) wire(rw uint8) []byte {
+	if !a.TenBit() {
+		return []byte{uint8(a.Value())<<1 | rw}
+	}
+	v := a.Value()
+	hi := 0xf0 | uint8(v>>7)&0x6
+	if rw == i2cSMBusRead {
+		return []byte{hi, uint8(v), hi | 1}
+	}
+	return []byte{hi, uint8(v)}
+}
+
+// SelectAddr selects the device at the 7-bit or 10-bit address a for
+// subsequent operations.
+// SelectAddr returns an error if a is out of range or is reserved.
+func (c *Conn) SelectAddr(a Addr) error {
+	c.lock()
+	defer c.unlock()
+
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	return c.selectAddr("set-addr", a, -1)
+}
+
+// selectAddr binds the address a to the adapter, for the operation op on
+// the designated register reg.
+// The I2C_SLAVE ioctl is only issued when a differs from the currently
+// bound address.
+func (c *Conn) selectAddr(op string, a Addr, reg int) error {
+	if c.bound && c.slave == a {
+		return nil
+	}
+
+	ev := c.begin(op, a, reg)
+	err := c.bind(op, a, reg)
+	if ev != nil && err != nil {
+		// the transaction never reaches the device: trace it here so
+		// tracers see the failure.
+		c.end(ev, err)
+	}
+	return err
+}
+
+// bind binds c to the device at address a.
+func (c *Conn) bind(op string, a Addr, reg int) error {
+	if a.TenBit() != c.tenbit {
+		var v uintptr
+		if a.TenBit() {
+			if err := c.check(op, a, reg, Func10BitAddr); err != nil {
+				return err
+			}
+			v = 1
+		}
+		if err := c.f.ioctl(i2cTenBit, v); err != nil {
+			return c.wrap(op, a, reg, err)
+		}
+		c.tenbit = a.TenBit()
+	}
+
+	req := uintptr(i2cSlave)
+	if c.force {
+		req = i2cSlaveForce
+	}
+	err := c.f.ioctl(req, uintptr(a.Value()))
+	c.bound = err == nil
+	if err == syscall.EBUSY {
+		err = &BusyError{Bus: c.bus, Addr: a, Driver: boundDriver(c.bus, a)}
+	}
+	if err != nil {
+		return c.wrap(op, a, reg, err)
+	}
+	c.slave = a
+	return nil
+}
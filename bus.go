@@ -0,0 +1,40 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus
+
+import (
+	"io"
+)
+
+// Bus is the interface implemented by SMBus connections.
+//
+// Sensor drivers should accept a Bus rather than a concrete *Conn so that
+// they can be used with fake, remote, multiplexed or instrumented buses.
+type Bus interface {
+	io.ReadWriteCloser
+
+	// SetAddr sets the address of the remote device for subsequent
+	// Read and Write operations.
+	SetAddr(addr uint8) error
+
+	// ReadReg reads a single byte from a designated register.
+	ReadReg(addr, reg uint8) (uint8, error)
+	// WriteReg writes a single byte v to a designated register.
+	WriteReg(addr, reg, v uint8) error
+
+	// ReadWord reads a 2-bytes word from a designated register.
+	ReadWord(addr, reg uint8) (uint16, error)
+	// WriteWord writes a 2-bytes word v to a designated register.
+	WriteWord(addr, reg uint8, v uint16) error
+
+	// ReadBlockData reads len(buf) data into the byte slice, from the designated register.
+	ReadBlockData(addr, reg uint8, buf []byte) error
+	// WriteBlockData writes the buf byte slice to a designated register.
+	WriteBlockData(addr, reg uint8, buf []byte) error
+}
+
+var (
+	_ Bus = (*Conn)(nil)
+)
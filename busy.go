@@ -0,0 +1,52 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// sysfs is the mount point of the sysfs filesystem.
+var sysfs = "/sys"
+
+// BusyError is returned when selecting a device already in use by a
+// kernel driver.
+// The Force option allows to access such a device anyways.
+type BusyError struct {
+	Bus    int    // i2c bus number
+	Addr   Addr   // address of the device
+	Driver string // name of the kernel driver bound to the device, if known
+}
+
+func (e *BusyError) Error() string {
+	drv := "a kernel driver"
+	if e.Driver != "" {
+		drv = fmt.Sprintf("kernel driver %q", e.Driver)
+	}
+	return fmt.Sprintf("smbus: device %v on bus %d is in use by %s", e.Addr, e.Bus, drv)
+}
+
+func (e *BusyError) Unwrap() error { return syscall.EBUSY }
+
+// boundDriver returns the name of the kernel driver bound to the device
+// at address addr on the provided i2c bus, or the empty string.
+func boundDriver(bus int, addr Addr) string {
+	if bus < 0 {
+		return ""
+	}
+	v := addr.Value()
+	if addr.TenBit() {
+		v |= 0xa000 // see I2C_ADDR_OFFSET_TEN_BIT
+	}
+	dir := filepath.Join(sysfs, "bus", "i2c", "devices", fmt.Sprintf("%d-%04x", bus, v))
+	dst, err := os.Readlink(filepath.Join(dir, "driver"))
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(dst)
+}
@@ -0,0 +1,220 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command i2cdetect scans an i2c bus for devices, like i2cdetect from
+// i2c-tools does.
+//
+// Usage:
+//
+//	i2cdetect [options] bus [first last]
+//	i2cdetect -F bus
+//	i2cdetect -l
+//
+// The bus is a bus number N, an "i2c-N" name, the path to an i2c-dev
+// character device or the name of an i2c adapter.
+//
+// The first and last addresses of the scanned range default to 0x03 and
+// 0x77 and must lie within that range, unless -a is given.
+//
+// Unless -y is given, i2cdetect asks for confirmation before probing.
+//
+// Example:
+//
+//	$> i2cdetect -y 1
+//	     0  1  2  3  4  5  6  7  8  9  a  b  c  d  e  f
+//	00:          -- -- -- -- -- -- -- -- -- -- -- -- --
+//	10: -- -- -- -- -- -- -- -- -- -- -- -- -- -- -- --
+//	20: -- -- -- -- -- -- -- -- -- 29 -- -- -- -- -- --
+//	30: -- -- -- -- -- -- -- -- -- -- -- -- -- -- -- --
+//	40: -- -- -- -- -- -- -- -- -- -- -- -- -- -- -- --
+//	50: -- -- -- -- -- -- -- -- -- -- -- -- -- -- -- --
+//	60: -- -- -- -- -- -- -- -- -- -- -- -- -- -- -- --
+//	70: -- -- -- -- -- -- UU --
+//
+//	$> i2cdetect -y -json 1
+//	{
+//	  "bus": "1",
+//	  "first": 3,
+//	  "last": 119,
+//	  "present": [
+//	    41
+//	  ],
+//	  "busy": [
+//	    118
+//	  ]
+//	}
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/go-daq/smbus"
+	"github.com/go-daq/smbus/internal/i2ctool"
+)
+
+func main() {
+	log.SetPrefix("i2cdetect: ")
+	log.SetFlags(0)
+
+	var (
+		yes    = flag.Bool("y", false, "disable interactive confirmation")
+		all    = flag.Bool("a", false, "scan all addresses, 0x00 to 0x7f")
+		quick  = flag.Bool("q", false, "probe with SMBus quick write")
+		read   = flag.Bool("r", false, "probe with SMBus receive byte")
+		funcs  = flag.Bool("F", false, "display the functionalities of the adapter")
+		list   = flag.Bool("l", false, "list the i2c adapters")
+		asJSON = flag.Bool("json", false, "enable JSON output")
+	)
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `i2cdetect scans an i2c bus for devices.
+
+Usage: i2cdetect [options] bus [first last]
+       i2cdetect -F bus
+       i2cdetect -l
+
+Options:
+`)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *list {
+		err := listAdapters(*asJSON)
+		if err != nil {
+			log.Fatalf("could not list adapters: %+v", err)
+		}
+		return
+	}
+
+	if n := flag.NArg(); n != 1 && n != 3 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *quick && *read {
+		log.Fatalf("-q and -r are mutually exclusive")
+	}
+
+	bus := flag.Arg(0)
+	conn, err := i2ctool.Open(bus, false)
+	if err != nil {
+		log.Fatalf("could not open bus %q: %+v", bus, err)
+	}
+	defer conn.Close()
+
+	if *funcs {
+		err = printFuncs(conn, bus, *asJSON)
+		if err != nil {
+			log.Fatalf("could not retrieve functionalities: %+v", err)
+		}
+		return
+	}
+
+	lo, hi := uint8(0x03), uint8(0x77)
+	if *all {
+		lo, hi = 0x00, 0x7f
+	}
+	first, last := lo, hi
+	if flag.NArg() == 3 {
+		v, err := i2ctool.ParseUint(flag.Arg(1), "first address", 7)
+		if err != nil {
+			log.Fatal(err)
+		}
+		first = uint8(v)
+		v, err = i2ctool.ParseUint(flag.Arg(2), "last address", 7)
+		if err != nil {
+			log.Fatal(err)
+		}
+		last = uint8(v)
+		if first > last || first < lo || last > hi {
+			log.Fatalf("invalid address range 0x%02x-0x%02x (valid range: 0x%02x-0x%02x)", first, last, lo, hi)
+		}
+	}
+
+	mode := smbus.ScanAuto
+	switch {
+	case *quick:
+		mode = smbus.ScanQuick
+	case *read:
+		mode = smbus.ScanRead
+	}
+
+	if !*yes {
+		msg := fmt.Sprintf("I will probe bus %s, address range 0x%02x-0x%02x.", bus, first, last)
+		if !i2ctool.Confirm(os.Stdin, os.Stderr, msg) {
+			log.Fatalf("aborting on user request")
+		}
+	}
+
+	r, err := smbus.ScanRange(conn, mode, first, last)
+	if err != nil {
+		log.Fatalf("could not scan bus %q: %+v", bus, err)
+	}
+
+	if !*asJSON {
+		fmt.Print(r)
+		return
+	}
+	err = i2ctool.WriteJSON(os.Stdout, struct {
+		Bus     string        `json:"bus"`
+		First   uint8         `json:"first"`
+		Last    uint8         `json:"last"`
+		Present i2ctool.Bytes `json:"present"`
+		Busy    i2ctool.Bytes `json:"busy"`
+	}{bus, first, last, r.Present(), r.Busy()})
+	if err != nil {
+		log.Fatalf("could not write JSON: %+v", err)
+	}
+}
+
+func listAdapters(asJSON bool) error {
+	adps, err := smbus.Adapters()
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		type adapter struct {
+			Bus  int    `json:"bus"`
+			Name string `json:"name"`
+			Mux  *int   `json:"mux_parent,omitempty"`
+		}
+		out := make([]adapter, 0, len(adps))
+		for _, adp := range adps {
+			v := adapter{Bus: adp.Number, Name: adp.Name}
+			if adp.MuxParent >= 0 {
+				mux := adp.MuxParent
+				v.Mux = &mux
+			}
+			out = append(out, v)
+		}
+		return i2ctool.WriteJSON(os.Stdout, out)
+	}
+	for _, adp := range adps {
+		fmt.Printf("i2c-%d\t%s\n", adp.Number, adp.Name)
+	}
+	return nil
+}
+
+func printFuncs(conn *smbus.Conn, bus string, asJSON bool) error {
+	fs, err := conn.Funcs()
+	if err != nil {
+		return err
+	}
+	names := strings.Split(fs.String(), "|")
+	if asJSON {
+		return i2ctool.WriteJSON(os.Stdout, struct {
+			Bus   string   `json:"bus"`
+			Funcs []string `json:"funcs"`
+		}{bus, names})
+	}
+	fmt.Printf("Functionalities implemented by bus %s:\n", bus)
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command i2cdump dumps the registers of an i2c device, like i2cdump from
+// i2c-tools does.
+//
+// Usage:
+//
+//	i2cdump [options] bus chip-address [mode]
+//
+// The bus is a bus number N, an "i2c-N" name, the path to an i2c-dev
+// character device or the name of an i2c adapter.
+//
+// The mode is one of:
+//
+//	b  read byte data, one register at a time (default)
+//	w  read word data, two registers at a time
+//	i  i2c block reads, 32 registers at a time
+//	c  send the first register, then receive consecutive bytes
+//
+// Registers that could not be read are shown as XX.
+//
+// Unless -y is given, i2cdump asks for confirmation before reading.
+//
+// Example:
+//
+//	$> i2cdump -y -r 0xf0-0xff 1 0x76
+//	     0  1  2  3  4  5  6  7  8  9  a  b  c  d  e  f    0123456789abcdef
+//	f0: 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00    ................
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/go-daq/smbus/internal/i2ctool"
+)
+
+func main() {
+	log.SetPrefix("i2cdump: ")
+	log.SetFlags(0)
+
+	var (
+		yes    = flag.Bool("y", false, "disable interactive confirmation")
+		all    = flag.Bool("a", false, "allow access to reserved addresses 0x00-0x07 and 0x78-0x7f")
+		force  = flag.Bool("f", false, "access the device even if it is in use by a kernel driver")
+		rng    = flag.String("r", "0x00-0xff", "range of registers to dump, as first-last")
+		asJSON = flag.Bool("json", false, "enable JSON output")
+	)
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `i2cdump dumps the registers of an i2c device.
+
+Usage: i2cdump [options] bus chip-address [mode]
+
+Modes: b (byte, default), w (word), i (i2c block), c (consecutive byte).
+
+Options:
+`)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if n := flag.NArg(); n < 2 || n > 3 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	bus := flag.Arg(0)
+	addr, err := i2ctool.ParseAddr(flag.Arg(1), *all)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mode := i2ctool.ModeByte
+	if flag.NArg() > 2 {
+		mode, err = i2ctool.ParseMode(flag.Arg(2), "bwic")
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	first, last, err := parseRange(*rng)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	conn, err := i2ctool.Open(bus, *force)
+	if err != nil {
+		log.Fatalf("could not open bus %q: %+v", bus, err)
+	}
+	defer conn.Close()
+
+	if !*yes {
+		msg := fmt.Sprintf(
+			"I will probe bus %s, chip address 0x%02x, registers 0x%02x-0x%02x, using %v mode.",
+			bus, addr, first, last, mode,
+		)
+		if !i2ctool.Confirm(os.Stdin, os.Stderr, msg) {
+			log.Fatalf("aborting on user request")
+		}
+	}
+
+	d, err := i2ctool.ReadDump(conn, addr, mode, first, last)
+	if err != nil {
+		log.Fatalf("could not dump device 0x%02x: %+v", addr, err)
+	}
+
+	if !*asJSON {
+		fmt.Print(d)
+		return
+	}
+	err = i2ctool.WriteJSON(os.Stdout, struct {
+		Bus  string        `json:"bus"`
+		Dump *i2ctool.Dump `json:"dump"`
+	}{bus, d})
+	if err != nil {
+		log.Fatalf("could not write JSON: %+v", err)
+	}
+}
+
+func parseRange(s string) (first, last uint8, err error) {
+	lo, hi, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid register range %q", s)
+	}
+	v, err := i2ctool.ParseUint(lo, "first register", 8)
+	if err != nil {
+		return 0, 0, err
+	}
+	first = uint8(v)
+	v, err = i2ctool.ParseUint(hi, "last register", 8)
+	if err != nil {
+		return 0, 0, err
+	}
+	last = uint8(v)
+	if first > last {
+		return 0, 0, fmt.Errorf("invalid register range %q", s)
+	}
+	return first, last, nil
+}
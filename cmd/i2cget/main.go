@@ -0,0 +1,174 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command i2cget reads a register of an i2c device, like i2cget from
+// i2c-tools does.
+//
+// Usage:
+//
+//	i2cget [options] bus chip-address [data-address [mode [length]]]
+//
+// The bus is a bus number N, an "i2c-N" name, the path to an i2c-dev
+// character device or the name of an i2c adapter.
+//
+// The mode is one of:
+//
+//	b  read byte data (default), or receive byte without data-address
+//	w  read word data
+//	c  send data-address, then receive byte
+//	s  SMBus block read
+//	i  i2c block read, of length bytes (default 32)
+//
+// Unless -y is given, i2cget asks for confirmation before reading.
+//
+// Example:
+//
+//	$> i2cget -y 1 0x76 0xd0
+//	0x60
+//	$> i2cget -y 1 0x76 0x88 w
+//	0x6b70
+//	$> i2cget -y -json 1 0x76 0xf7 i 3
+//	{
+//	  "bus": "1",
+//	  "addr": 118,
+//	  "reg": 247,
+//	  "mode": "i2c-block",
+//	  "data": [
+//	    101,
+//	    90,
+//	    192
+//	  ]
+//	}
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/go-daq/smbus/internal/i2ctool"
+)
+
+func main() {
+	log.SetPrefix("i2cget: ")
+	log.SetFlags(0)
+
+	var (
+		yes    = flag.Bool("y", false, "disable interactive confirmation")
+		all    = flag.Bool("a", false, "allow access to reserved addresses 0x00-0x07 and 0x78-0x7f")
+		force  = flag.Bool("f", false, "access the device even if it is in use by a kernel driver")
+		asJSON = flag.Bool("json", false, "enable JSON output")
+	)
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `i2cget reads a register of an i2c device.
+
+Usage: i2cget [options] bus chip-address [data-address [mode [length]]]
+
+Modes: b (byte, default), w (word), c (write byte/read byte),
+       s (SMBus block), i (i2c block, with optional length).
+
+Options:
+`)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if n := flag.NArg(); n < 2 || n > 5 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	bus := flag.Arg(0)
+	addr, err := i2ctool.ParseAddr(flag.Arg(1), *all)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var (
+		reg  = -1
+		mode = i2ctool.ModeByte
+		n    = 32
+	)
+	if flag.NArg() > 2 {
+		v, err := i2ctool.ParseUint(flag.Arg(2), "data address", 8)
+		if err != nil {
+			log.Fatal(err)
+		}
+		reg = int(v)
+	}
+	if flag.NArg() > 3 {
+		mode, err = i2ctool.ParseMode(flag.Arg(3), "bwcsi")
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if flag.NArg() > 4 {
+		if mode != i2ctool.ModeI2CBlock {
+			log.Fatalf("length is only valid for the i mode")
+		}
+		v, err := i2ctool.ParseUint(flag.Arg(4), "length", 8)
+		if err != nil || v < 1 || v > 32 {
+			log.Fatalf("invalid length %q (valid lengths: 1-32)", flag.Arg(4))
+		}
+		n = int(v)
+	}
+	if reg < 0 && mode != i2ctool.ModeByte {
+		log.Fatalf("mode %v requires a data address", mode)
+	}
+
+	conn, err := i2ctool.Open(bus, *force)
+	if err != nil {
+		log.Fatalf("could not open bus %q: %+v", bus, err)
+	}
+	defer conn.Close()
+
+	if !*yes {
+		msg := fmt.Sprintf("I will read from bus %s, chip address 0x%02x", bus, addr)
+		if reg >= 0 {
+			msg += fmt.Sprintf(", data address 0x%02x", reg)
+		}
+		msg += fmt.Sprintf(", using %v mode.", mode)
+		if !i2ctool.Confirm(os.Stdin, os.Stderr, msg) {
+			log.Fatalf("aborting on user request")
+		}
+	}
+
+	data, err := i2ctool.Get(conn, addr, reg, mode, n)
+	if err != nil {
+		log.Fatalf("read failed: %+v", err)
+	}
+
+	if !*asJSON {
+		fmt.Println(i2ctool.Format(mode, data))
+		return
+	}
+
+	out := struct {
+		Bus   string        `json:"bus"`
+		Addr  uint8         `json:"addr"`
+		Reg   *int          `json:"reg,omitempty"`
+		Mode  i2ctool.Mode  `json:"mode"`
+		Value *uint16       `json:"value,omitempty"`
+		Data  i2ctool.Bytes `json:"data,omitempty"`
+	}{Bus: bus, Addr: addr, Mode: mode}
+	if reg >= 0 {
+		out.Reg = &reg
+	}
+	switch mode {
+	case i2ctool.ModeByte, i2ctool.ModeConsecutive:
+		v := uint16(data[0])
+		out.Value = &v
+	case i2ctool.ModeWord:
+		v := uint16(data[0]) | uint16(data[1])<<8
+		out.Value = &v
+	default:
+		out.Data = data
+	}
+	err = i2ctool.WriteJSON(os.Stdout, out)
+	if err != nil {
+		log.Fatalf("could not write JSON: %+v", err)
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command i2cset writes a register of an i2c device, like i2cset from
+// i2c-tools does.
+//
+// Usage:
+//
+//	i2cset [options] bus chip-address data-address [value...] [mode]
+//
+// The bus is a bus number N, an "i2c-N" name, the path to an i2c-dev
+// character device or the name of an i2c adapter.
+//
+// The mode is one of:
+//
+//	c  send data-address as a single byte, without value (default without value)
+//	b  write byte data (default with a value)
+//	w  write word data
+//	s  SMBus block write of the values
+//	i  i2c block write of the values
+//
+// With -m, only the bits of the value set in the mask are written: the
+// other bits are read from the register first.
+// With -r, the register is read back after the write.
+//
+// Unless -y is given, i2cset asks for confirmation before writing.
+//
+// Example:
+//
+//	$> i2cset -y 1 0x76 0xf4 0x27
+//	$> i2cset -y -m 0x03 -r 1 0x76 0xf4 0x01
+//	0x25
+//	$> i2cset -y 1 0x50 0x10 0xde 0xad 0xbe 0xef i
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/go-daq/smbus/internal/i2ctool"
+)
+
+func main() {
+	log.SetPrefix("i2cset: ")
+	log.SetFlags(0)
+
+	var (
+		yes      = flag.Bool("y", false, "disable interactive confirmation")
+		all      = flag.Bool("a", false, "allow access to reserved addresses 0x00-0x07 and 0x78-0x7f")
+		force    = flag.Bool("f", false, "access the device even if it is in use by a kernel driver")
+		mask     = flag.String("m", "", "mask of the bits to write (b and w modes)")
+		readback = flag.Bool("r", false, "read back the register after the write (b and w modes)")
+		asJSON   = flag.Bool("json", false, "enable JSON output")
+	)
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `i2cset writes a register of an i2c device.
+
+Usage: i2cset [options] bus chip-address data-address [value...] [mode]
+
+Modes: c (send byte, no value), b (byte, default), w (word),
+       s (SMBus block), i (i2c block).
+
+Options:
+`)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) < 3 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	bus := args[0]
+	addr, err := i2ctool.ParseAddr(args[1], *all)
+	if err != nil {
+		log.Fatal(err)
+	}
+	v, err := i2ctool.ParseUint(args[2], "data address", 8)
+	if err != nil {
+		log.Fatal(err)
+	}
+	reg := uint8(v)
+
+	vals := args[3:]
+	mode := i2ctool.ModeByte
+	if len(vals) == 0 {
+		mode = i2ctool.ModeConsecutive
+	}
+	if n := len(vals); n > 0 && len(vals[n-1]) == 1 && strings.Contains("cbwsi", vals[n-1]) {
+		mode, err = i2ctool.ParseMode(vals[n-1], "cbwsi")
+		if err != nil {
+			log.Fatal(err)
+		}
+		vals = vals[:n-1]
+	}
+
+	data, err := values(mode, vals)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var m uint64
+	if *mask != "" || *readback {
+		if mode != i2ctool.ModeByte && mode != i2ctool.ModeWord {
+			log.Fatalf("-m and -r are only valid for the b and w modes")
+		}
+	}
+	if *mask != "" {
+		m, err = i2ctool.ParseUint(*mask, "mask", 8*len(data))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	conn, err := i2ctool.Open(bus, *force)
+	if err != nil {
+		log.Fatalf("could not open bus %q: %+v", bus, err)
+	}
+	defer conn.Close()
+
+	if !*yes {
+		msg := fmt.Sprintf("I will write to bus %s, chip address 0x%02x, data address 0x%02x", bus, addr, reg)
+		if len(data) > 0 {
+			msg += ", data " + i2ctool.Format(mode, data)
+		}
+		if *mask != "" {
+			msg += fmt.Sprintf(", mask 0x%0*x", 2*len(data), m)
+		}
+		msg += fmt.Sprintf(", using %v mode.", mode)
+		if !i2ctool.Confirm(os.Stdin, os.Stderr, msg) {
+			log.Fatalf("aborting on user request")
+		}
+	}
+
+	if *mask != "" {
+		old, err := i2ctool.Get(conn, addr, int(reg), mode, 0)
+		if err != nil {
+			log.Fatalf("could not read old value: %+v", err)
+		}
+		for i := range data {
+			mi := uint8(m >> (8 * i))
+			data[i] = data[i]&mi | old[i]&^mi
+		}
+	}
+
+	err = i2ctool.Set(conn, addr, reg, mode, data)
+	if err != nil {
+		log.Fatalf("write failed: %+v", err)
+	}
+
+	var back []byte
+	if *readback {
+		back, err = i2ctool.Get(conn, addr, int(reg), mode, 0)
+		if err != nil {
+			log.Fatalf("could not read back value: %+v", err)
+		}
+	}
+
+	if !*asJSON {
+		if *readback {
+			fmt.Println(i2ctool.Format(mode, back))
+		}
+		return
+	}
+
+	err = i2ctool.WriteJSON(os.Stdout, struct {
+		Bus      string        `json:"bus"`
+		Addr     uint8         `json:"addr"`
+		Reg      uint8         `json:"reg"`
+		Mode     i2ctool.Mode  `json:"mode"`
+		Data     i2ctool.Bytes `json:"data"`
+		Readback i2ctool.Bytes `json:"readback,omitempty"`
+	}{bus, addr, reg, mode, data, back})
+	if err != nil {
+		log.Fatalf("could not write JSON: %+v", err)
+	}
+}
+
+// values parses the values to write with the provided mode.
+// Words are returned in bus order, low byte first.
+func values(mode i2ctool.Mode, vals []string) ([]byte, error) {
+	switch mode {
+	case i2ctool.ModeConsecutive:
+		if len(vals) != 0 {
+			return nil, fmt.Errorf("mode c takes no value")
+		}
+		return nil, nil
+	case i2ctool.ModeByte, i2ctool.ModeWord:
+		if len(vals) != 1 {
+			return nil, fmt.Errorf("mode %c takes a single value", mode)
+		}
+		if mode == i2ctool.ModeByte {
+			v, err := i2ctool.ParseUint(vals[0], "value", 8)
+			return []byte{uint8(v)}, err
+		}
+		v, err := i2ctool.ParseUint(vals[0], "value", 16)
+		return []byte{uint8(v), uint8(v >> 8)}, err
+	}
+
+	if len(vals) < 1 || len(vals) > 32 {
+		return nil, fmt.Errorf("mode %c takes 1 to 32 values", mode)
+	}
+	data := make([]byte, len(vals))
+	for i, s := range vals {
+		v, err := i2ctool.ParseUint(s, "value", 8)
+		if err != nil {
+			return nil, err
+		}
+		data[i] = uint8(v)
+	}
+	return data, nil
+}
@@ -0,0 +1,195 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command smbusd exports i2c adapters over TCP.
+//
+// Usage:
+//
+//	smbusd [options] bus [bus...]
+//
+// Each bus is either the number N of an i2c adapter, exported as "i2c-N",
+// or a name=path pair, exporting the i2c-dev character device at path
+// under name (e.g. sensors=/dev/i2c-sensors).
+//
+// Clients connect with remote.Dial. See package remote for the protocol.
+//
+// Clients are denied access unless an access policy is provided with
+// -policy, or full access is explicitly granted to all clients with
+// -allow-all.
+//
+// The -policy flag restricts the operations of clients to the rules of a
+// JSON policy file, mapping authentication tokens to client identities,
+// and client identities to their access policy:
+//
+//	{
+//	  "tokens": {"s3cr3t": "logger"},
+//	  "policies": {
+//	    "logger": {"rules": [{"addrs": [118], "regs": [247, 248, 249], "access": "r"}]},
+//	    "admin":  {"rules": [{"access": "rwl"}]}
+//	  }
+//	}
+//
+// Access rights combine r (read), w (write) and l (lock the bus for
+// multi-step sequences). A client may hold the lock of a bus for at most
+// -lock-timeout.
+//
+// With -tls-cert and -tls-key, clients connect over TLS. With -tls-ca,
+// clients presenting a certificate signed by that CA are identified by the
+// common name of their certificate.
+//
+// Example:
+//
+//	$> smbusd -addr :5555 -allow-all 1 sensors=/dev/i2c-sensors
+//	$> smbusd -policy policy.json -tls-cert srv.pem -tls-key srv.key -tls-ca ca.pem 1
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-daq/smbus"
+	"github.com/go-daq/smbus/remote"
+)
+
+func main() {
+	log.SetPrefix("smbusd: ")
+	log.SetFlags(0)
+
+	var (
+		addr     = flag.String("addr", ":5555", "address to listen on")
+		force    = flag.Bool("force", false, "access devices even if they are in use by a kernel driver")
+		pfile    = flag.String("policy", "", "path to a JSON access policy file")
+		cert     = flag.String("tls-cert", "", "path to the TLS certificate of the server")
+		key      = flag.String("tls-key", "", "path to the TLS key of the server")
+		ca       = flag.String("tls-ca", "", "path to the CA certificates verifying client certificates")
+		allowAll = flag.Bool("allow-all", false, "grant full access to all clients, without access policy")
+		lockTime = flag.Duration("lock-timeout", remote.DefaultLockTimeout, "maximum duration a client may hold the lock of a bus")
+	)
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `smbusd exports i2c adapters over TCP.
+
+Usage: smbusd [options] bus [bus...]
+
+Each bus is either the number N of an i2c adapter, exported as "i2c-N",
+or a name=path pair, exporting the i2c-dev character device at path under name.
+
+Options:
+`)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	srv := remote.NewServer()
+	srv.Logger = slog.Default()
+	srv.LockTimeout = *lockTime
+	switch {
+	case *pfile != "" && *allowAll:
+		log.Fatalf("-policy and -allow-all are mutually exclusive")
+	case *pfile != "":
+		err := loadPolicy(srv, *pfile)
+		if err != nil {
+			log.Fatalf("could not load policy: %+v", err)
+		}
+	case *allowAll:
+		log.Printf("WARNING: no access policy, all clients have full access to the exported buses")
+	default:
+		log.Fatalf("no access policy: use -policy, or -allow-all to grant full access to all clients")
+	}
+	for _, arg := range flag.Args() {
+		name, conn, err := open(arg, *force)
+		if err != nil {
+			log.Fatalf("could not open bus %q: %+v", arg, err)
+		}
+		defer conn.Close()
+		srv.Export(name, conn)
+		log.Printf("exporting %q", name)
+	}
+
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("could not listen on %q: %+v", *addr, err)
+	}
+	if *cert != "" || *key != "" {
+		cfg, err := tlsConfig(*cert, *key, *ca)
+		if err != nil {
+			log.Fatalf("could not configure TLS: %+v", err)
+		}
+		l = tls.NewListener(l, cfg)
+	}
+	log.Printf("listening on %v", l.Addr())
+
+	err = srv.Serve(l)
+	if err != nil {
+		log.Fatalf("could not serve: %+v", err)
+	}
+}
+
+func open(arg string, force bool) (string, *smbus.Conn, error) {
+	if name, path, ok := strings.Cut(arg, "="); ok {
+		conn, err := smbus.OpenPath(path, smbus.Force(force))
+		return name, conn, err
+	}
+	bus, err := strconv.Atoi(arg)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid bus number: %w", err)
+	}
+	conn, err := smbus.OpenFile(bus, smbus.Force(force))
+	return "i2c-" + arg, conn, err
+}
+
+func loadPolicy(srv *remote.Server, fname string) error {
+	raw, err := os.ReadFile(fname)
+	if err != nil {
+		return err
+	}
+	var cfg struct {
+		Tokens   map[string]string         `json:"tokens"`
+		Policies map[string]*remote.Policy `json:"policies"`
+	}
+	err = json.Unmarshal(raw, &cfg)
+	if err != nil {
+		return fmt.Errorf("could not decode %q: %w", fname, err)
+	}
+	srv.Tokens = cfg.Tokens
+	srv.Policies = cfg.Policies
+	if srv.Policies == nil {
+		srv.Policies = make(map[string]*remote.Policy)
+	}
+	return nil
+}
+
+func tlsConfig(cert, key, ca string) (*tls.Config, error) {
+	crt, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{crt}}
+	if ca != "" {
+		raw, err := os.ReadFile(ca)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(raw) {
+			return nil, fmt.Errorf("no certificate in %q", ca)
+		}
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return cfg, nil
+}
@@ -0,0 +1,312 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-daq/smbus"
+	"github.com/go-daq/smbus/smbustest"
+)
+
+const simFuncs = smbus.FuncI2C | smbus.FuncSMBusQuick | smbus.FuncSMBusByte |
+	smbus.FuncSMBusByteData | smbus.FuncSMBusWordData | smbus.FuncSMBusProcCall |
+	smbus.FuncSMBusBlock | smbus.FuncSMBusI2CBlock | smbus.FuncSMBusBlockProcCall |
+	smbus.FuncSMBusPEC
+
+func newSimConn(addrs ...uint8) (*smbus.Conn, *smbustest.Bus) {
+	return newSimConnFuncs(simFuncs, addrs...)
+}
+
+func newSimConnFuncs(funcs smbus.Funcs, addrs ...uint8) (*smbus.Conn, *smbustest.Bus) {
+	bus := smbustest.New()
+	for _, addr := range addrs {
+		bus.Attach(addr, &smbustest.Device{})
+	}
+	return smbus.NewSimConn(bus, funcs), bus
+}
+
+func TestAddrCache(t *testing.T) {
+	c, _ := newSimConn(0x76, 0x77)
+	defer c.Close()
+
+	for i := 0; i < 10; i++ {
+		_, err := c.ReadReg(0x76, 0xfa)
+		if err != nil {
+			t.Fatalf("read-reg error: %v", err)
+		}
+	}
+	if got, want := c.Ioctls()[smbus.IoctlSlave], 1; got != want {
+		t.Fatalf("invalid number of I2C_SLAVE ioctls: got=%d, want=%d", got, want)
+	}
+
+	for i := 0; i < 10; i++ {
+		addr := uint8(0x76 + i%2)
+		err := c.WriteReg(addr, 0xf4, 0x3f)
+		if err != nil {
+			t.Fatalf("write-reg error: %v", err)
+		}
+	}
+	if got, want := c.Ioctls()[smbus.IoctlSlave], 1+10-1; got != want {
+		t.Fatalf("invalid number of I2C_SLAVE ioctls: got=%d, want=%d", got, want)
+	}
+
+	err := c.SetAddr(0x77)
+	if err != nil {
+		t.Fatalf("set-addr error: %v", err)
+	}
+	if got, want := c.Ioctls()[smbus.IoctlSlave], 10; got != want {
+		t.Fatalf("invalid number of I2C_SLAVE ioctls: got=%d, want=%d", got, want)
+	}
+}
+
+func BenchmarkReadReg(b *testing.B) {
+	for _, bc := range []struct {
+		name  string
+		addrs []uint8
+	}{
+		{"same-addr", []uint8{0x76}},
+		{"alternating-addr", []uint8{0x76, 0x77}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			c, _ := newSimConn(bc.addrs...)
+			defer c.Close()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := c.ReadReg(bc.addrs[i%len(bc.addrs)], 0xfa)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+
+			ioctls := 0
+			for _, n := range c.Ioctls() {
+				ioctls += n
+			}
+			b.ReportMetric(float64(ioctls)/float64(b.N), "ioctls/op")
+			b.ReportMetric(float64(c.Ioctls()[smbus.IoctlSlave])/float64(b.N), "slave-ioctls/op")
+		})
+	}
+}
+
+func TestTenBit(t *testing.T) {
+	c, bus := newSimConnFuncs(simFuncs|smbus.Func10BitAddr, 0x76)
+	defer c.Close()
+	bus.Device(0x76).MustSet(0x10, 0x76)
+
+	ten := smbustest.New()
+	dev := &smbustest.Device{}
+	dev.MustSet(0x10, 0xab)
+	ten.Attach(0xa2, dev)
+	c.AttachTenBit(0x3a2, ten)
+	a := smbus.Addr10(0x3a2)
+
+	v, err := c.ReadRegAt(a, 0x10)
+	if err != nil {
+		t.Fatalf("read-reg error: %v", err)
+	}
+	if v != 0xab {
+		t.Fatalf("invalid register: got=0x%02x, want=0xab", v)
+	}
+	err = c.WriteWordAt(a, 0x20, 0x1234)
+	if err != nil {
+		t.Fatalf("write-word error: %v", err)
+	}
+	err = c.WriteI2CBlockDataAt(a, 0x22, []byte{1, 2})
+	if err != nil {
+		t.Fatalf("write-i2c-block-data error: %v", err)
+	}
+	buf := make([]byte, 4)
+	err = c.ReadI2CBlockDataAt(a, 0x20, buf)
+	if err != nil {
+		t.Fatalf("read-i2c-block-data error: %v", err)
+	}
+	if want := []byte{0x34, 0x12, 1, 2}; string(buf) != string(want) {
+		t.Fatalf("invalid block: got=%x, want=%x", buf, want)
+	}
+	err = c.WriteRegAt(a, 0x30, 0xcd)
+	if err != nil {
+		t.Fatalf("write-reg error: %v", err)
+	}
+	w, err := c.ReadWordAt(a, 0x30)
+	if err != nil {
+		t.Fatalf("read-word error: %v", err)
+	}
+	if w != 0x00cd {
+		t.Fatalf("invalid word: got=0x%04x, want=0x00cd", w)
+	}
+
+	// 7-bit devices are still reachable, with both APIs.
+	v, err = c.ReadReg(0x76, 0x10)
+	if err != nil || v != 0x76 {
+		t.Fatalf("read-reg of 7-bit device: v=0x%02x, err=%v", v, err)
+	}
+	v, err = c.ReadRegAt(smbus.Addr7(0x76), 0x10)
+	if err != nil || v != 0x76 {
+		t.Fatalf("read-reg-at of 7-bit device: v=0x%02x, err=%v", v, err)
+	}
+
+	_, err = c.ReadRegAt(smbus.Addr10(0x400), 0x10)
+	if err == nil {
+		t.Fatalf("expected an error for an invalid 10-bit address")
+	}
+	_, err = c.ReadRegAt(smbus.Addr10(0x123), 0x10)
+	if !errors.Is(err, smbus.ErrNACK) {
+		t.Fatalf("invalid error for an absent device: got=%v, want=%v", err, smbus.ErrNACK)
+	}
+
+	c7, _ := newSimConn(0x76)
+	defer c7.Close()
+	_, err = c7.ReadRegAt(a, 0x10)
+	if !errors.Is(err, smbus.ErrUnsupported) {
+		t.Fatalf("invalid error without 10-bit support: got=%v, want=%v", err, smbus.ErrUnsupported)
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	c, bus := newSimConn(0x40, 0x41)
+	defer c.Close()
+
+	for _, addr := range []uint8{0x40, 0x41} {
+		bus.Device(addr).MustSet(0x10, addr, addr)
+	}
+
+	var wg sync.WaitGroup
+	errc := make(chan error, 2)
+	for _, addr := range []uint8{0x40, 0x41} {
+		wg.Add(1)
+		go func(addr uint8) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				var buf [2]byte
+				err := c.WithLock(func(b smbus.Bus) error {
+					err := b.SetAddr(addr)
+					if err != nil {
+						return err
+					}
+					_, err = b.Write([]byte{0x10})
+					if err != nil {
+						return err
+					}
+					_, err = b.Read(buf[:])
+					return err
+				})
+				if err != nil {
+					errc <- err
+					return
+				}
+				if buf[0] != addr || buf[1] != addr {
+					errc <- fmt.Errorf("read from wrong device: got=%v, want=0x%x", buf, addr)
+					return
+				}
+
+				v, err := c.ReadReg(addr, 0x10)
+				if err != nil {
+					errc <- err
+					return
+				}
+				if v != addr {
+					errc <- fmt.Errorf("read-reg from wrong device: got=0x%x, want=0x%x", v, addr)
+					return
+				}
+			}
+		}(addr)
+	}
+	wg.Wait()
+	close(errc)
+
+	for err := range errc {
+		t.Error(err)
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	c, _ := newSimConn(0x76)
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cc := c.WithContext(ctx)
+
+	_, err := cc.ReadReg(0x76, 0xd0)
+	if err != nil {
+		t.Fatalf("read-reg error: %v", err)
+	}
+
+	cancel()
+	_, err = cc.ReadReg(0x76, 0xd0)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, context.Canceled)
+	}
+
+	_, err = c.ReadReg(0x76, 0xd0)
+	if err != nil {
+		t.Fatalf("read-reg error: %v", err)
+	}
+}
+
+func TestError(t *testing.T) {
+	c, bus := newSimConnFuncs(smbus.FuncI2C|smbus.FuncSMBusByteData, 0x76, 0x77)
+	defer c.Close()
+
+	_, err := c.ReadReg(0x42, 0xd0)
+	if !errors.Is(err, smbus.ErrNACK) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, smbus.ErrNACK)
+	}
+	if errors.Is(err, smbus.ErrTimeout) || errors.Is(err, smbus.ErrBusBusy) {
+		t.Fatalf("invalid error classification: %v", err)
+	}
+	var e *smbus.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("error should be a *smbus.Error: %T", err)
+	}
+	if e.Op != "read-reg" || e.Addr != smbus.Addr7(0x42) || e.Reg != 0xd0 {
+		t.Fatalf("invalid error: %+v", e)
+	}
+	if got, want := err.Error(), "smbus: read-reg addr=0x42 reg=0xd0: no such device or address"; got != want {
+		t.Fatalf("invalid error message:\ngot= %s\nwant=%s", got, want)
+	}
+
+	_, err = c.ReadWord(0x76, 0xd0)
+	if !errors.Is(err, smbus.ErrUnsupported) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, smbus.ErrUnsupported)
+	}
+	if !errors.As(err, &e) || e.Op != "read-word" || e.Addr != smbus.Addr7(0x76) || e.Reg != 0xd0 {
+		t.Fatalf("invalid error: %v", err)
+	}
+
+	err = c.SetPEC(true)
+	if !errors.Is(err, smbus.ErrUnsupported) || !errors.As(err, &e) || e.Op != "set-pec" {
+		t.Fatalf("invalid error: %v", err)
+	}
+
+	err = c.SetRetries(-1)
+	if err == nil {
+		t.Fatalf("expected an error for a negative number of retries")
+	}
+	err = c.SetTimeout(-time.Second)
+	if err == nil {
+		t.Fatalf("expected an error for a negative timeout")
+	}
+
+	bus.Device(0x77).Busy = true
+	_, err = c.ReadReg(0x77, 0xd0)
+	var busy *smbus.BusyError
+	if !errors.As(err, &busy) {
+		t.Fatalf("error should wrap a *smbus.BusyError: %v", err)
+	}
+	if errors.Is(err, smbus.ErrBusBusy) {
+		t.Fatalf("device in use by a kernel driver should not be classified as a busy bus")
+	}
+	if !errors.As(err, &e) || e.Op != "read-reg" || e.Addr != smbus.Addr7(0x77) || e.Reg != 0xd0 {
+		t.Fatalf("invalid error: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus
+
+import (
+	"context"
+)
+
+// WithContext returns a view of the connection whose operations honour
+// the provided context: operations fail with ctx.Err() when ctx is done
+// before they start, and retries are abandoned as soon as ctx is done.
+//
+// A transaction already submitted to the kernel is not interrupted.
+func (c *Conn) WithContext(ctx context.Context) *Conn {
+	if ctx == nil {
+		panic("smbus: nil context")
+	}
+	return &Conn{conn: c.conn, held: c.held, ctx: ctx}
+}
+
+// Context returns the context of the connection operations.
+// The returned context is always non-nil; it defaults to the background context.
+func (c *Conn) Context() context.Context {
+	return c.context()
+}
+
+func (c *Conn) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// WithContext returns a view of the bus b whose operations honour ctx,
+// if b supports it. Otherwise, WithContext returns b.
+//
+// Buses support contexts by implementing a WithContext(context.Context) Bus method.
+func WithContext(ctx context.Context, b Bus) Bus {
+	switch b := b.(type) {
+	case *Conn:
+		return b.WithContext(ctx)
+	case interface {
+		WithContext(ctx context.Context) Bus
+	}:
+		return b.WithContext(ctx)
+	}
+	return b
+}
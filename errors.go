@@ -0,0 +1,122 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// Classes of bus failures.
+// Errors returned by Conn can be tested against these classes with errors.Is.
+var (
+	// ErrNACK reports that the device did not acknowledge its address
+	// or its data (ENXIO, EREMOTEIO).
+	ErrNACK = errors.New("smbus: no acknowledge from device")
+
+	// ErrTimeout reports that the transaction timed out (ETIMEDOUT).
+	ErrTimeout = errors.New("smbus: transaction timed out")
+
+	// ErrBusBusy reports that the bus was busy or that the adapter lost
+	// the arbitration of the bus (EBUSY, EAGAIN).
+	ErrBusBusy = errors.New("smbus: bus busy")
+)
+
+// Error describes a failed bus operation.
+type Error struct {
+	Op   string // operation, e.g. "read-reg"
+	Bus  int    // i2c bus number, or -1 if unknown
+	Addr Addr   // address of the device
+	Reg  int    // designated register, or -1 if not applicable
+	Err  error  // underlying error
+}
+
+func (e *Error) Error() string {
+	msg := "smbus: " + e.Op
+	if e.Bus >= 0 {
+		msg += fmt.Sprintf(" bus=%d", e.Bus)
+	}
+	msg += fmt.Sprintf(" addr=%v", e.Addr)
+	if e.Reg >= 0 {
+		msg += fmt.Sprintf(" reg=0x%02x", e.Reg)
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Is reports whether e belongs to the class of failures target,
+// one of ErrNACK, ErrTimeout, ErrBusBusy or ErrUnsupported.
+func (e *Error) Is(target error) bool {
+	class := classify(e.Err)
+	return class != nil && class == target
+}
+
+// classify returns the class of failure of err, or nil.
+func classify(err error) error {
+	var busy *BusyError
+	if errors.As(err, &busy) {
+		// the device is in use by a kernel driver, the bus is not busy.
+		return nil
+	}
+
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return nil
+	}
+	switch errno {
+	case syscall.ENXIO, syscall.EREMOTEIO:
+		return ErrNACK
+	case syscall.ETIMEDOUT:
+		return ErrTimeout
+	case syscall.EBUSY, syscall.EAGAIN:
+		return ErrBusBusy
+	case syscall.EOPNOTSUPP:
+		return ErrUnsupported
+	}
+	return nil
+}
+
+// wrap returns err wrapped into an Error describing the operation op
+// on the designated register reg of the device at address addr.
+// wrap returns nil if err is nil.
+func (c *Conn) wrap(op string, addr Addr, reg int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Op: op, Bus: c.bus, Addr: addr, Reg: reg, Err: err}
+}
+
+// opName returns the name of the SMBus operation described by cmd, and
+// its designated register, or -1 if the operation designates no register.
+func opName(cmd *i2cCmd) (string, int) {
+	rw := func(r, w string) string {
+		if cmd.rw == i2cSMBusRead {
+			return r
+		}
+		return w
+	}
+	reg := int(cmd.cmd)
+	switch cmd.len {
+	case i2cSMBusQuick:
+		return "write-quick", -1
+	case i2cSMBusByte:
+		return rw("receive-byte", "send-byte"), -1
+	case i2cSMBusByteData:
+		return rw("read-reg", "write-reg"), reg
+	case i2cSMBusWordData:
+		return rw("read-word", "write-word"), reg
+	case i2cSMBusProcCall:
+		return "process-call", reg
+	case i2cSMBusBlockData:
+		return rw("read-smbus-block", "write-smbus-block"), reg
+	case i2cSMBusBlockProcCall:
+		return "block-process-call", reg
+	case i2cSMBusI2CBlockData:
+		return rw("read-i2c-block-data", "write-i2c-block-data"), reg
+	}
+	return fmt.Sprintf("smbus-%d", cmd.len), reg
+}
@@ -0,0 +1,205 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus
+
+import (
+	"context"
+	"io"
+	"syscall"
+	"unsafe"
+)
+
+// SetSysfs sets the sysfs mount point and returns a function restoring
+// the previous one.
+func SetSysfs(root string) func() {
+	old := sysfs
+	sysfs = root
+	return func() { sysfs = old }
+}
+
+var BoundDriver = boundDriver
+
+func (p RetryPolicy) Do(ctx context.Context, f func() error) error { return p.do(ctx, f) }
+
+// SimBus is the API of a simulated bus (see smbustest.Bus) used to
+// emulate the i2c-dev kernel interface in tests.
+type SimBus interface {
+	io.ReadWriteCloser
+	SetAddr(addr uint8) error
+	WriteQuick(addr, bit uint8) error
+	SendByte(addr, v uint8) error
+	ReceiveByte(addr uint8) (uint8, error)
+	ReadReg(addr, reg uint8) (uint8, error)
+	WriteReg(addr, reg, v uint8) error
+	ReadWord(addr, reg uint8) (uint16, error)
+	WriteWord(addr, reg uint8, v uint16) error
+	ReadI2CBlockData(addr, reg uint8, buf []byte) error
+	WriteI2CBlockData(addr, reg uint8, buf []byte) error
+	ReadSMBusBlock(addr, reg uint8) ([]byte, error)
+	WriteSMBusBlock(addr, reg uint8, buf []byte) error
+	ProcessCall(addr, reg uint8, v uint16) (uint16, error)
+	BlockProcessCall(addr, reg uint8, buf []byte) ([]byte, error)
+	Transfer(msgs ...Msg) error
+}
+
+// simDevice emulates the i2c-dev kernel interface on top of a simulated bus.
+type simDevice struct {
+	SimBus
+	funcs  Funcs
+	slave  uint16
+	tenbit bool              // whether slave is a 10-bit address
+	ten    map[uint16]SimBus // buses of the 10-bit devices, by address
+	ioctls map[uintptr]int
+}
+
+// NewSimConn returns a Conn connected to a simulated bus, with an adapter
+// providing the funcs functionalities.
+func NewSimConn(bus SimBus, funcs Funcs) *Conn {
+	return &Conn{conn: &conn{
+		f:   &simDevice{SimBus: bus, funcs: funcs, ioctls: make(map[uintptr]int)},
+		bus: -1,
+	}}
+}
+
+// AttachTenBit attaches the device at the address uint8(a) of bus at the
+// 10-bit address a of the simulated bus of c.
+func (c *Conn) AttachTenBit(a uint16, bus SimBus) {
+	dev := c.f.(*simDevice)
+	if dev.ten == nil {
+		dev.ten = make(map[uint16]SimBus)
+	}
+	dev.ten[a] = bus
+}
+
+// Ioctls returns the number of ioctl system calls issued on the simulated
+// bus, per request.
+func (c *Conn) Ioctls() map[uintptr]int {
+	return c.f.(*simDevice).ioctls
+}
+
+const (
+	IoctlSlave = i2cSlave
+	IoctlSMBus = i2cSMBus
+	IoctlRdWr  = i2cRdWr
+)
+
+func (dev *simDevice) ioctl(req, arg uintptr) error {
+	dev.ioctls[req]++
+	switch req {
+	case i2cSlave, i2cSlaveForce:
+		dev.slave = uint16(arg)
+		if dev.tenbit {
+			return nil
+		}
+		return dev.SetAddr(uint8(dev.slave))
+	case i2cTenBit:
+		if arg != 0 && !dev.funcs.Has(Func10BitAddr) {
+			return syscall.EINVAL
+		}
+		dev.tenbit = arg != 0
+		return nil
+	case i2cPEC, i2cTimeout, i2cRetries:
+		return nil
+	}
+	return syscall.ENOTTY
+}
+
+func (dev *simDevice) ioctlPtr(req uintptr, p unsafe.Pointer) error {
+	dev.ioctls[req]++
+	switch req {
+	case i2cFuncs:
+		*(*uint)(p) = uint(dev.funcs)
+		return nil
+	case i2cSMBus:
+		return dev.smbus((*i2cCmd)(p))
+	case i2cRdWr:
+		data := (*i2cRdWrData)(p)
+		kmsgs := unsafe.Slice((*i2cMsg)(data.msgs), data.nmsgs)
+		msgs := make([]Msg, len(kmsgs))
+		for i, m := range kmsgs {
+			msgs[i] = Msg{Addr: Addr(m.addr), Flags: MsgFlags(m.flags)}
+			if m.len > 0 {
+				msgs[i].Buf = unsafe.Slice((*byte)(m.buf), m.len)
+			}
+		}
+		return dev.Transfer(msgs...)
+	}
+	return syscall.ENOTTY
+}
+
+// target returns the bus and address of the selected device.
+func (dev *simDevice) target() (SimBus, uint8, error) {
+	if !dev.tenbit {
+		return dev.SimBus, uint8(dev.slave), nil
+	}
+	bus, ok := dev.ten[dev.slave]
+	if !ok {
+		return nil, 0, syscall.ENXIO
+	}
+	return bus, uint8(dev.slave), nil
+}
+
+func (dev *simDevice) smbus(cmd *i2cCmd) error {
+	bus, addr, err := dev.target()
+	if err != nil {
+		return err
+	}
+	switch cmd.len {
+	case i2cSMBusQuick:
+		return bus.WriteQuick(addr, cmd.rw)
+	case i2cSMBusByte:
+		if cmd.rw == i2cSMBusWrite {
+			return bus.SendByte(addr, cmd.cmd)
+		}
+		*(*uint8)(cmd.ptr), err = bus.ReceiveByte(addr)
+		return err
+	case i2cSMBusByteData:
+		v := (*uint8)(cmd.ptr)
+		if cmd.rw == i2cSMBusWrite {
+			return bus.WriteReg(addr, cmd.cmd, *v)
+		}
+		*v, err = bus.ReadReg(addr, cmd.cmd)
+		return err
+	case i2cSMBusWordData:
+		v := (*uint16)(cmd.ptr)
+		if cmd.rw == i2cSMBusWrite {
+			return bus.WriteWord(addr, cmd.cmd, *v)
+		}
+		*v, err = bus.ReadWord(addr, cmd.cmd)
+		return err
+	case i2cSMBusProcCall:
+		v := (*uint16)(cmd.ptr)
+		*v, err = bus.ProcessCall(addr, cmd.cmd, *v)
+		return err
+	case i2cSMBusI2CBlockData:
+		data := unsafe.Slice((*byte)(cmd.ptr), i2cSMBusBlockMax+2)
+		buf := data[1 : 1+data[0]]
+		if cmd.rw == i2cSMBusWrite {
+			return bus.WriteI2CBlockData(addr, cmd.cmd, buf)
+		}
+		return bus.ReadI2CBlockData(addr, cmd.cmd, buf)
+	case i2cSMBusBlockData, i2cSMBusBlockProcCall:
+		data := unsafe.Slice((*byte)(cmd.ptr), i2cSMBusBlockMax+2)
+		var out []byte
+		switch {
+		case cmd.len == i2cSMBusBlockProcCall:
+			out, err = bus.BlockProcessCall(addr, cmd.cmd, data[1:1+data[0]])
+		case cmd.rw == i2cSMBusWrite:
+			return bus.WriteSMBusBlock(addr, cmd.cmd, data[1:1+data[0]])
+		default:
+			out, err = bus.ReadSMBusBlock(addr, cmd.cmd)
+		}
+		if err != nil {
+			return err
+		}
+		data[0] = byte(copy(data[1:], out))
+		return nil
+	}
+	return syscall.EINVAL
+}
+
+var FindAdapter = findAdapter
+
+func (c *Conn) BusNumber() int { return c.bus }
@@ -0,0 +1,143 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// Funcs is a bitmask describing the functionalities of an i2c adapter,
+// as reported by the I2C_FUNCS ioctl.
+type Funcs uint32
+
+// Adapter functionalities.
+const (
+	FuncI2C                Funcs = 0x00000001 // plain i2c-level transfers (I2C_RDWR)
+	Func10BitAddr          Funcs = 0x00000002 // 10-bit addresses
+	FuncProtocolMangling   Funcs = 0x00000004 // I2C_M_IGNORE_NAK, I2C_M_REV_DIR_ADDR, ...
+	FuncSMBusPEC           Funcs = 0x00000008 // Packet Error Checking
+	FuncNoStart            Funcs = 0x00000010 // I2C_M_NOSTART
+	FuncSlave              Funcs = 0x00000020 // slave mode
+	FuncSMBusBlockProcCall Funcs = 0x00008000 // SMBus block write-block read process call
+	FuncSMBusQuick         Funcs = 0x00010000 // SMBus quick command
+	FuncSMBusReadByte      Funcs = 0x00020000 // SMBus receive byte
+	FuncSMBusWriteByte     Funcs = 0x00040000 // SMBus send byte
+	FuncSMBusReadByteData  Funcs = 0x00080000 // SMBus read byte
+	FuncSMBusWriteByteData Funcs = 0x00100000 // SMBus write byte
+	FuncSMBusReadWordData  Funcs = 0x00200000 // SMBus read word
+	FuncSMBusWriteWordData Funcs = 0x00400000 // SMBus write word
+	FuncSMBusProcCall      Funcs = 0x00800000 // SMBus process call
+	FuncSMBusReadBlock     Funcs = 0x01000000 // SMBus block read
+	FuncSMBusWriteBlock    Funcs = 0x02000000 // SMBus block write
+	FuncSMBusReadI2CBlock  Funcs = 0x04000000 // i2c block read, with caller-chosen length
+	FuncSMBusWriteI2CBlock Funcs = 0x08000000 // i2c block write, with caller-chosen length
+	FuncSMBusHostNotify    Funcs = 0x10000000 // SMBus host notify
+
+	FuncSMBusByte     = FuncSMBusReadByte | FuncSMBusWriteByte
+	FuncSMBusByteData = FuncSMBusReadByteData | FuncSMBusWriteByteData
+	FuncSMBusWordData = FuncSMBusReadWordData | FuncSMBusWriteWordData
+	FuncSMBusBlock    = FuncSMBusReadBlock | FuncSMBusWriteBlock
+	FuncSMBusI2CBlock = FuncSMBusReadI2CBlock | FuncSMBusWriteI2CBlock
+)
+
+var funcNames = []struct {
+	f    Funcs
+	name string
+}{
+	{FuncI2C, "I2C"},
+	{Func10BitAddr, "10BIT_ADDR"},
+	{FuncProtocolMangling, "PROTOCOL_MANGLING"},
+	{FuncSMBusPEC, "SMBUS_PEC"},
+	{FuncNoStart, "NOSTART"},
+	{FuncSlave, "SLAVE"},
+	{FuncSMBusBlockProcCall, "SMBUS_BLOCK_PROC_CALL"},
+	{FuncSMBusQuick, "SMBUS_QUICK"},
+	{FuncSMBusReadByte, "SMBUS_READ_BYTE"},
+	{FuncSMBusWriteByte, "SMBUS_WRITE_BYTE"},
+	{FuncSMBusReadByteData, "SMBUS_READ_BYTE_DATA"},
+	{FuncSMBusWriteByteData, "SMBUS_WRITE_BYTE_DATA"},
+	{FuncSMBusReadWordData, "SMBUS_READ_WORD_DATA"},
+	{FuncSMBusWriteWordData, "SMBUS_WRITE_WORD_DATA"},
+	{FuncSMBusProcCall, "SMBUS_PROC_CALL"},
+	{FuncSMBusReadBlock, "SMBUS_READ_BLOCK_DATA"},
+	{FuncSMBusWriteBlock, "SMBUS_WRITE_BLOCK_DATA"},
+	{FuncSMBusReadI2CBlock, "SMBUS_READ_I2C_BLOCK"},
+	{FuncSMBusWriteI2CBlock, "SMBUS_WRITE_I2C_BLOCK"},
+	{FuncSMBusHostNotify, "SMBUS_HOST_NOTIFY"},
+}
+
+// Has returns whether all the functionalities in f are provided.
+func (fs Funcs) Has(f Funcs) bool {
+	return fs&f == f
+}
+
+// String returns the list of functionalities in fs, separated by '|'.
+func (fs Funcs) String() string {
+	if fs == 0 {
+		return "0"
+	}
+	var names []string
+	for _, v := range funcNames {
+		if fs&v.f != 0 {
+			names = append(names, v.name)
+			fs &^= v.f
+		}
+	}
+	if fs != 0 {
+		names = append(names, fmt.Sprintf("0x%x", uint32(fs)))
+	}
+	return strings.Join(names, "|")
+}
+
+var (
+	// ErrUnsupported is returned when an operation is not supported
+	// by the i2c adapter.
+	ErrUnsupported = errors.New("smbus: operation unsupported by adapter")
+)
+
+// Funcs returns the functionalities supported by the i2c adapter.
+func (c *Conn) Funcs() (Funcs, error) {
+	c.lock()
+	defer c.unlock()
+
+	fs, err := c.adapterFuncs()
+	if err != nil {
+		return 0, c.wrap("funcs", c.slave, -1, err)
+	}
+	return fs, nil
+}
+
+func (c *Conn) adapterFuncs() (Funcs, error) {
+	if c.funcs != nil {
+		return *c.funcs, nil
+	}
+
+	var v uint // unsigned long
+	err := c.f.ioctlPtr(i2cFuncs, unsafe.Pointer(&v))
+	if err != nil {
+		return 0, err
+	}
+	fs := Funcs(v)
+	c.funcs = &fs
+	return fs, nil
+}
+
+// check returns ErrUnsupported if the adapter does not support
+// all the functionalities in f, needed by the operation op on the
+// designated register reg of the device at address addr.
+// Adapters that can not be queried are assumed to support op.
+func (c *Conn) check(op string, addr Addr, reg int, f Funcs) error {
+	fs, err := c.adapterFuncs()
+	if err != nil {
+		return nil
+	}
+	if !fs.Has(f) {
+		return c.wrap(op, addr, reg, fmt.Errorf("%w (missing %v)", ErrUnsupported, f&^fs))
+	}
+	return nil
+}
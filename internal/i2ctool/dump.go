@@ -0,0 +1,152 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package i2ctool
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Dump holds the registers First to Last of a device.
+type Dump struct {
+	Addr  uint8
+	Mode  Mode
+	First uint8
+	Last  uint8
+
+	Regs  [256]uint8
+	Valid [256]bool // whether the register could be read
+
+	err error // last read error
+}
+
+// ReadDump reads the registers first to last (inclusive) of the device at
+// address addr, with the provided mode: ModeByte, ModeWord, ModeI2CBlock
+// or ModeConsecutive.
+//
+// Registers that can not be read are marked as invalid: ReadDump only
+// fails when the device can not be accessed at all, i.e. when none of
+// the registers could be read.
+func ReadDump(b Bus, addr uint8, mode Mode, first, last uint8) (*Dump, error) {
+	if first > last {
+		return nil, fmt.Errorf("invalid register range 0x%02x-0x%02x", first, last)
+	}
+	d := &Dump{Addr: addr, Mode: mode, First: first, Last: last}
+	switch mode {
+	case ModeByte:
+		for reg := int(first); reg <= int(last); reg++ {
+			v, err := b.ReadReg(addr, uint8(reg))
+			d.set(reg, []byte{v}, err)
+		}
+	case ModeWord:
+		for reg := int(first); reg <= int(last); reg += 2 {
+			v, err := b.ReadWord(addr, uint8(reg))
+			d.set(reg, []byte{uint8(v), uint8(v >> 8)}, err)
+		}
+	case ModeI2CBlock:
+		for reg := int(first); reg <= int(last); reg += 32 {
+			buf := make([]byte, min(32, int(last)-reg+1))
+			err := b.ReadI2CBlockData(addr, uint8(reg), buf)
+			d.set(reg, buf, err)
+		}
+	case ModeConsecutive:
+		err := b.SendByte(addr, first)
+		if err != nil {
+			return nil, err
+		}
+		for reg := int(first); reg <= int(last); reg++ {
+			v, err := b.ReceiveByte(addr)
+			d.set(reg, []byte{v}, err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid dump mode %v", mode)
+	}
+	if d.err != nil && !d.valid() {
+		return nil, d.err
+	}
+	return d, nil
+}
+
+// valid reports whether at least one register could be read.
+func (d *Dump) valid() bool {
+	for reg := int(d.First); reg <= int(d.Last); reg++ {
+		if d.Valid[reg] {
+			return true
+		}
+	}
+	return false
+}
+
+// set records the values vs of the registers starting at reg, up to Last.
+func (d *Dump) set(reg int, vs []byte, err error) {
+	if err != nil {
+		d.err = err
+	}
+	for i, v := range vs {
+		if reg+i > int(d.Last) {
+			break
+		}
+		d.Regs[reg+i] = v
+		d.Valid[reg+i] = err == nil
+	}
+}
+
+// String returns the dump formatted as a table of 16 registers per row,
+// with their hex and ASCII representations, like i2cdump does.
+// Registers that could not be read are shown as XX.
+func (d *Dump) String() string {
+	var o strings.Builder
+	o.WriteString("     0  1  2  3  4  5  6  7  8  9  a  b  c  d  e  f    0123456789abcdef\n")
+	for row := int(d.First) &^ 0xf; row <= int(d.Last); row += 16 {
+		var ascii [16]byte
+		fmt.Fprintf(&o, "%02x: ", row)
+		for i := range ascii {
+			reg := row + i
+			switch {
+			case reg < int(d.First) || reg > int(d.Last):
+				o.WriteString("   ")
+				ascii[i] = ' '
+			case !d.Valid[reg]:
+				o.WriteString("XX ")
+				ascii[i] = 'X'
+			default:
+				v := d.Regs[reg]
+				fmt.Fprintf(&o, "%02x ", v)
+				switch {
+				case v == 0x00 || v == 0xff:
+					ascii[i] = '.'
+				case v < 0x20 || v > 0x7e:
+					ascii[i] = '?'
+				default:
+					ascii[i] = v
+				}
+			}
+		}
+		fmt.Fprintf(&o, "   %s\n", strings.TrimRight(string(ascii[:]), " "))
+	}
+	return o.String()
+}
+
+// MarshalJSON implements json.Marshaler.
+// Registers are listed from First to Last, with null for the registers
+// that could not be read.
+func (d *Dump) MarshalJSON() ([]byte, error) {
+	regs := make([]*uint8, 0, int(d.Last)-int(d.First)+1)
+	for reg := int(d.First); reg <= int(d.Last); reg++ {
+		var v *uint8
+		if d.Valid[reg] {
+			v = &d.Regs[reg]
+		}
+		regs = append(regs, v)
+	}
+	return json.Marshal(struct {
+		Addr  uint8    `json:"addr"`
+		Mode  Mode     `json:"mode"`
+		First uint8    `json:"first"`
+		Last  uint8    `json:"last"`
+		Regs  []*uint8 `json:"regs"`
+	}{d.Addr, d.Mode, d.First, d.Last, regs})
+}
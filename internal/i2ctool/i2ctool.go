@@ -0,0 +1,216 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package i2ctool holds the code shared by the i2cdetect, i2cget, i2cset
+// and i2cdump commands.
+package i2ctool
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-daq/smbus"
+)
+
+// Bus is the interface of the buses accessed by the commands.
+// Bus is implemented by *smbus.Conn and *smbustest.Bus.
+type Bus interface {
+	smbus.Bus
+
+	SendByte(addr, v uint8) error
+	ReceiveByte(addr uint8) (uint8, error)
+	ReadSMBusBlock(addr, reg uint8) ([]byte, error)
+	WriteSMBusBlock(addr, reg uint8, buf []byte) error
+	ReadI2CBlockData(addr, reg uint8, buf []byte) error
+	WriteI2CBlockData(addr, reg uint8, buf []byte) error
+}
+
+// Open opens the i2c adapter designated by bus: a bus number N, an "i2c-N"
+// name, the path to an i2c-dev character device, or the name of the adapter.
+func Open(bus string, force bool) (*smbus.Conn, error) {
+	if n, err := strconv.Atoi(strings.TrimPrefix(bus, "i2c-")); err == nil {
+		return smbus.OpenFile(n, smbus.Force(force))
+	}
+	if strings.HasPrefix(bus, "/") {
+		return smbus.OpenPath(bus, smbus.Force(force))
+	}
+	return smbus.OpenByName(bus, smbus.Force(force))
+}
+
+// ParseAddr parses the 7-bit address of a device.
+// Addresses outside of the 0x08-0x77 range are reserved, and only
+// accepted when all is true.
+func ParseAddr(s string, all bool) (uint8, error) {
+	v, err := strconv.ParseUint(s, 0, 8)
+	if err != nil || v > 0x7f {
+		return 0, fmt.Errorf("invalid chip address %q", s)
+	}
+	if !all && (v < 0x08 || v > 0x77) {
+		return 0, fmt.Errorf("chip address 0x%02x out of range 0x08-0x77 (use -a to force)", v)
+	}
+	return uint8(v), nil
+}
+
+// ParseUint parses an unsigned integer of at most bits bits.
+// what describes the value, for error reporting.
+func ParseUint(s, what string, bits int) (uint64, error) {
+	v, err := strconv.ParseUint(s, 0, bits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", what, s)
+	}
+	return v, nil
+}
+
+// Mode describes the SMBus transactions used to access a device.
+type Mode byte
+
+// Modes, named after their i2c-tools letter.
+const (
+	ModeByte        Mode = 'b' // read/write byte data, or receive byte without register
+	ModeWord        Mode = 'w' // read/write word data
+	ModeSMBusBlock  Mode = 's' // SMBus block read/write, with a byte count
+	ModeI2CBlock    Mode = 'i' // i2c block read/write, without byte count
+	ModeConsecutive Mode = 'c' // send byte, then receive byte(s)
+)
+
+// ParseMode parses a mode letter, among the valid ones.
+func ParseMode(s, valid string) (Mode, error) {
+	if len(s) != 1 || !strings.Contains(valid, s) {
+		return 0, fmt.Errorf("invalid mode %q (valid modes: %s)", s, strings.Join(strings.Split(valid, ""), ", "))
+	}
+	return Mode(s[0]), nil
+}
+
+func (m Mode) String() string {
+	switch m {
+	case ModeByte:
+		return "byte"
+	case ModeWord:
+		return "word"
+	case ModeSMBusBlock:
+		return "smbus-block"
+	case ModeI2CBlock:
+		return "i2c-block"
+	case ModeConsecutive:
+		return "consecutive"
+	}
+	return fmt.Sprintf("Mode(%q)", byte(m))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (m Mode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// Get reads data from the device at address addr with the provided mode.
+// A negative reg designates no register: ModeByte then performs a receive
+// byte. n is the number of bytes read by ModeI2CBlock.
+// Words are returned in bus order, low byte first.
+func Get(b Bus, addr uint8, reg int, mode Mode, n int) ([]byte, error) {
+	switch mode {
+	case ModeByte:
+		if reg < 0 {
+			v, err := b.ReceiveByte(addr)
+			return []byte{v}, err
+		}
+		v, err := b.ReadReg(addr, uint8(reg))
+		return []byte{v}, err
+	case ModeConsecutive:
+		err := b.SendByte(addr, uint8(reg))
+		if err != nil {
+			return nil, err
+		}
+		v, err := b.ReceiveByte(addr)
+		return []byte{v}, err
+	case ModeWord:
+		v, err := b.ReadWord(addr, uint8(reg))
+		return []byte{uint8(v), uint8(v >> 8)}, err
+	case ModeSMBusBlock:
+		return b.ReadSMBusBlock(addr, uint8(reg))
+	case ModeI2CBlock:
+		buf := make([]byte, n)
+		err := b.ReadI2CBlockData(addr, uint8(reg), buf)
+		return buf, err
+	}
+	return nil, fmt.Errorf("invalid mode %v", mode)
+}
+
+// Set writes data to the register reg of the device at address addr with
+// the provided mode. ModeConsecutive sends reg as a single byte, without
+// data. Words are provided in bus order, low byte first.
+func Set(b Bus, addr, reg uint8, mode Mode, data []byte) error {
+	switch mode {
+	case ModeConsecutive:
+		return b.SendByte(addr, reg)
+	case ModeByte:
+		return b.WriteReg(addr, reg, data[0])
+	case ModeWord:
+		return b.WriteWord(addr, reg, uint16(data[0])|uint16(data[1])<<8)
+	case ModeSMBusBlock:
+		return b.WriteSMBusBlock(addr, reg, data)
+	case ModeI2CBlock:
+		return b.WriteI2CBlockData(addr, reg, data)
+	}
+	return fmt.Errorf("invalid mode %v", mode)
+}
+
+// Format formats data read or written with the provided mode, like
+// i2c-tools do: 0xNN for bytes, 0xNNNN for words and a list of bytes
+// for blocks.
+func Format(mode Mode, data []byte) string {
+	switch mode {
+	case ModeByte, ModeConsecutive:
+		if len(data) == 1 {
+			return fmt.Sprintf("0x%02x", data[0])
+		}
+	case ModeWord:
+		if len(data) == 2 {
+			return fmt.Sprintf("0x%04x", uint16(data[0])|uint16(data[1])<<8)
+		}
+	}
+	vs := make([]string, len(data))
+	for i, v := range data {
+		vs[i] = fmt.Sprintf("0x%02x", v)
+	}
+	return strings.Join(vs, " ")
+}
+
+// Bytes is a byte slice, marshaled to JSON as an array of numbers
+// rather than as a base64 string.
+type Bytes []byte
+
+// MarshalJSON implements json.Marshaler.
+func (p Bytes) MarshalJSON() ([]byte, error) {
+	vs := make([]int, len(p))
+	for i, v := range p {
+		vs[i] = int(v)
+	}
+	return json.Marshal(vs)
+}
+
+// WriteJSON writes v to w as indented JSON.
+func WriteJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// Confirm writes the warning msg to w, and asks the user to confirm from r.
+// Confirm returns whether the user accepted, the default answer.
+func Confirm(r io.Reader, w io.Writer, msg string) bool {
+	fmt.Fprintf(w, "WARNING! This program can confuse your I2C bus, cause data loss and worse!\n%s\nContinue? [Y/n] ", msg)
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && line == "" {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "", "y", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package i2ctool
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-daq/smbus"
+	"github.com/go-daq/smbus/smbustest"
+)
+
+var _ Bus = (*smbus.Conn)(nil)
+
+func newBus() *smbustest.Bus {
+	bus := smbustest.New()
+	dev := &smbustest.Device{}
+	dev.MustSet(0x00, 0x60, 0x12, 0x34)
+	dev.MustSet(0x10, []byte("go-daq")...)
+	dev.MustSet(0x1e, 0x01, 0xff)
+	bus.Attach(0x76, dev)
+	return bus
+}
+
+func TestParseAddr(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		all  bool
+		want uint8
+		err  bool
+	}{
+		{s: "0x76", want: 0x76},
+		{s: "118", want: 0x76},
+		{s: "0x03", err: true},
+		{s: "0x03", all: true, want: 0x03},
+		{s: "0x78", err: true},
+		{s: "0x80", all: true, err: true},
+		{s: "bad", err: true},
+	} {
+		got, err := ParseAddr(tc.s, tc.all)
+		if (err != nil) != tc.err {
+			t.Errorf("parse(%q, %v): invalid error: %v", tc.s, tc.all, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("parse(%q, %v): got=0x%02x, want=0x%02x", tc.s, tc.all, got, tc.want)
+		}
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	bus := newBus()
+	for _, tc := range []struct {
+		reg  int
+		mode Mode
+		n    int
+		want string
+	}{
+		{0x00, ModeByte, 0, "0x60"},
+		{-1, ModeByte, 0, "0x12"}, // pointer left after register 0x00
+		{0x01, ModeWord, 0, "0x3412"},
+		{0x01, ModeConsecutive, 0, "0x12"},
+		{0x10, ModeI2CBlock, 3, "0x67 0x6f 0x2d"},
+	} {
+		got, err := Get(bus, 0x76, tc.reg, tc.mode, tc.n)
+		if err != nil {
+			t.Fatalf("get(0x%02x, %v) error: %v", tc.reg, tc.mode, err)
+		}
+		if got := Format(tc.mode, got); got != tc.want {
+			t.Fatalf("get(0x%02x, %v): got=%q, want=%q", tc.reg, tc.mode, got, tc.want)
+		}
+	}
+
+	err := Set(bus, 0x76, 0x20, ModeWord, []byte{0xcd, 0xab})
+	if err != nil {
+		t.Fatalf("set error: %v", err)
+	}
+	err = Set(bus, 0x76, 0x22, ModeI2CBlock, []byte{1, 2, 3})
+	if err != nil {
+		t.Fatalf("set error: %v", err)
+	}
+	if got, want := bus.Device(0x76).Regs[0x20:0x25], []byte{0xcd, 0xab, 1, 2, 3}; !bytes.Equal(got, want) {
+		t.Fatalf("invalid registers: got=%x, want=%x", got, want)
+	}
+
+	_, err = Get(bus, 0x42, 0x00, ModeByte, 0)
+	if err == nil {
+		t.Fatalf("expected an error for an absent device")
+	}
+}
+
+func TestDump(t *testing.T) {
+	bus := newBus()
+	want := strings.Join([]string{
+		"     0  1  2  3  4  5  6  7  8  9  a  b  c  d  e  f    0123456789abcdef",
+		"00:    12 34 00 00 00 00 00 00 00 00 00 00 00 00 00     ?4.............",
+		"10: 67 6f 2d 64 61 71 00 00 00 00 00 00 00 00 01 ff    go-daq........?.",
+		"20: 00 00                                              ..",
+		"",
+	}, "\n")
+
+	for _, mode := range []Mode{ModeByte, ModeWord, ModeI2CBlock, ModeConsecutive} {
+		d, err := ReadDump(bus, 0x76, mode, 0x01, 0x21)
+		if err != nil {
+			t.Fatalf("dump(%v) error: %v", mode, err)
+		}
+		if got := d.String(); got != want {
+			t.Fatalf("invalid %v dump:\ngot:\n%s\nwant:\n%s", mode, got, want)
+		}
+	}
+
+	_, err := ReadDump(bus, 0x42, ModeByte, 0x00, 0xff)
+	if err == nil {
+		t.Fatalf("expected an error for an absent device")
+	}
+
+	bus.Device(0x76).ReadHook = func(dev *smbustest.Device, reg uint8) error {
+		if reg >= 0xfe {
+			return smbus.ErrNACK
+		}
+		return nil
+	}
+	d, err := ReadDump(bus, 0x76, ModeByte, 0xfd, 0xff)
+	if err != nil {
+		t.Fatalf("dump error: %v", err)
+	}
+	if got, want := d.String(), "f0: "+strings.Repeat("   ", 13)+"00 XX XX    "+strings.Repeat(" ", 13)+".XX\n"; !strings.HasSuffix(got, want) {
+		t.Fatalf("invalid dump:\ngot:\n%q\nwant:\n%q", got, want)
+	}
+
+	raw, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("could not marshal dump: %v", err)
+	}
+	if got, want := string(raw), `{"addr":118,"mode":"byte","first":253,"last":255,"regs":[0,null,null]}`; got != want {
+		t.Fatalf("invalid JSON:\ngot= %s\nwant=%s", got, want)
+	}
+}
+
+func TestBytes(t *testing.T) {
+	raw, err := json.Marshal(Bytes{0x01, 0xff})
+	if err != nil {
+		t.Fatalf("could not marshal bytes: %v", err)
+	}
+	var got []int
+	err = json.Unmarshal(raw, &got)
+	if err != nil {
+		t.Fatalf("could not unmarshal bytes: %v", err)
+	}
+	if want := []int{1, 255}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid bytes: got=%v, want=%v", got, want)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want bool
+	}{
+		{"\n", true},
+		{"y\n", true},
+		{"Yes\n", true},
+		{"n\n", false},
+		{"", false},
+	} {
+		var out strings.Builder
+		got := Confirm(strings.NewReader(tc.in), &out, "I will probe.")
+		if got != tc.want {
+			t.Errorf("confirm(%q): got=%v, want=%v", tc.in, got, tc.want)
+		}
+		if !strings.Contains(out.String(), "I will probe.\nContinue? [Y/n] ") {
+			t.Errorf("invalid prompt: %q", out.String())
+		}
+	}
+}
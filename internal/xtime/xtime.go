@@ -0,0 +1,33 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package xtime provides context-aware time functions.
+package xtime
+
+import (
+	"context"
+	"time"
+)
+
+// Sleep pauses the current goroutine for at least the duration d,
+// or until ctx is done.
+// Sleep returns ctx.Err() if ctx is done before d elapsed.
+func Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus
+
+// Locker is implemented by buses providing exclusive access for
+// multi-step sequences of transactions.
+type Locker interface {
+	// WithLock calls f with a view of the bus that has exclusive access to
+	// the bus. Other users of the bus are blocked until f returns.
+	WithLock(f func(b Bus) error) error
+}
+
+// WithLock calls f with exclusive access to the bus b, if b implements
+// Locker. Otherwise, WithLock calls f with b.
+func WithLock(b Bus, f func(b Bus) error) error {
+	if l, ok := b.(Locker); ok {
+		return l.WithLock(f)
+	}
+	return f(b)
+}
+
+// WithLock calls f with a locked view of the connection.
+// Operations on the view are performed without re-acquiring the lock
+// of c: f must not use c directly, nor retain the view after it returns.
+func (c *Conn) WithLock(f func(b Bus) error) error {
+	c.lock()
+	defer c.unlock()
+
+	return f(&Conn{conn: c.conn, held: true, ctx: c.ctx})
+}
+
+func (c *Conn) lock() {
+	if !c.held {
+		c.mu.Lock()
+	}
+}
+
+func (c *Conn) unlock() {
+	if !c.held {
+		c.mu.Unlock()
+	}
+}
+
+var (
+	_ Locker = (*Conn)(nil)
+)
@@ -0,0 +1,230 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBounds are the upper bounds of the latency histogram
+// buckets used by NewMetrics.
+var DefaultLatencyBounds = []time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	1 * time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+}
+
+// Metrics collects statistics about the transactions of one or more
+// connections, per device: devices are identified by their bus number
+// and address.
+//
+// Metrics is a Tracer: it is enabled on a connection with SetTracer or
+// the Trace option, possibly combined with other tracers with MultiTracer.
+// Metrics implements expvar.Var and can be published with expvar.Publish.
+//
+// Metrics is safe for concurrent use.
+type Metrics struct {
+	mu     sync.Mutex
+	bounds []time.Duration
+	devs   map[devKey]*DeviceStats
+}
+
+// devKey identifies a device across buses.
+type devKey struct {
+	bus  int
+	addr Addr
+}
+
+// DeviceStats holds the statistics of the transactions with a device.
+type DeviceStats struct {
+	Bus          int               `json:"-"` // i2c bus number, or -1 if unknown
+	Addr         Addr              `json:"-"`
+	Ops          map[string]uint64 `json:"ops"`           // number of transactions, per operation
+	BytesRead    uint64            `json:"bytes_read"`    // number of bytes received from the device
+	BytesWritten uint64            `json:"bytes_written"` // number of bytes sent to the device
+	Errors       map[string]uint64 `json:"errors"`        // number of failed transactions, per class
+	Latency      Histogram         `json:"latency"`       // duration of the transactions
+}
+
+// Histogram is a histogram of transaction durations.
+type Histogram struct {
+	Bounds []time.Duration `json:"bounds"` // upper bounds of the buckets, in ascending order
+	Counts []uint64        `json:"counts"` // counts per bucket; the last bucket holds durations above all bounds
+	Count  uint64          `json:"count"`  // total number of durations
+	Sum    time.Duration   `json:"sum"`    // sum of all durations
+}
+
+func (h *Histogram) add(d time.Duration) {
+	i := sort.Search(len(h.Bounds), func(i int) bool { return d <= h.Bounds[i] })
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+}
+
+// Mean returns the mean duration of the histogram.
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Error classes of DeviceStats.Errors.
+const (
+	ErrClassNACK        = "nack"
+	ErrClassTimeout     = "timeout"
+	ErrClassBusy        = "busy"
+	ErrClassUnsupported = "unsupported"
+	ErrClassCanceled    = "canceled"
+	ErrClassOther       = "other"
+)
+
+func errClass(err error) string {
+	switch {
+	case errors.Is(err, ErrNACK):
+		return ErrClassNACK
+	case errors.Is(err, ErrTimeout):
+		return ErrClassTimeout
+	case errors.Is(err, ErrBusBusy):
+		return ErrClassBusy
+	case errors.Is(err, ErrUnsupported):
+		return ErrClassUnsupported
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ErrClassCanceled
+	}
+	return ErrClassOther
+}
+
+// NewMetrics returns new metrics, with latency histograms bucketed by
+// DefaultLatencyBounds.
+func NewMetrics() *Metrics {
+	return NewMetricsWithBounds(DefaultLatencyBounds)
+}
+
+// NewMetricsWithBounds returns new metrics, with latency histograms
+// bucketed by the provided upper bounds, in ascending order.
+func NewMetricsWithBounds(bounds []time.Duration) *Metrics {
+	return &Metrics{
+		bounds: append([]time.Duration(nil), bounds...),
+		devs:   make(map[devKey]*DeviceStats),
+	}
+}
+
+// Trace records the transaction ev.
+func (m *Metrics) Trace(ev Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := devKey{ev.Bus, ev.Addr}
+	st, ok := m.devs[key]
+	if !ok {
+		st = &DeviceStats{
+			Bus:    ev.Bus,
+			Addr:   ev.Addr,
+			Ops:    make(map[string]uint64),
+			Errors: make(map[string]uint64),
+			Latency: Histogram{
+				Bounds: m.bounds,
+				Counts: make([]uint64, len(m.bounds)+1),
+			},
+		}
+		m.devs[key] = st
+	}
+	st.Ops[ev.Op]++
+	st.BytesRead += uint64(len(ev.Read))
+	st.BytesWritten += uint64(len(ev.Write))
+	if ev.Err != nil {
+		st.Errors[errClass(ev.Err)]++
+	}
+	st.Latency.add(ev.Duration)
+}
+
+// Snapshot returns a copy of the current statistics, sorted by bus number
+// and address.
+func (m *Metrics) Snapshot() []DeviceStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]DeviceStats, 0, len(m.devs))
+	for _, st := range m.devs {
+		v := *st
+		v.Ops = make(map[string]uint64, len(st.Ops))
+		for k, n := range st.Ops {
+			v.Ops[k] = n
+		}
+		v.Errors = make(map[string]uint64, len(st.Errors))
+		for k, n := range st.Errors {
+			v.Errors[k] = n
+		}
+		v.Latency.Counts = append([]uint64(nil), st.Latency.Counts...)
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Bus != out[j].Bus {
+			return out[i].Bus < out[j].Bus
+		}
+		return out[i].Addr < out[j].Addr
+	})
+	return out
+}
+
+// Reset discards all the collected statistics.
+func (m *Metrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.devs = make(map[devKey]*DeviceStats)
+}
+
+// String returns the current statistics as a JSON object, keyed by
+// device: "i2c-N/0xAA" for the device at address 0xAA on bus N, or the
+// address alone when the bus is unknown. String implements expvar.Var.
+func (m *Metrics) String() string {
+	snap := m.Snapshot()
+	devs := make(map[string]DeviceStats, len(snap))
+	for _, st := range snap {
+		devs[st.key()] = st
+	}
+	buf, err := json.Marshal(devs)
+	if err != nil {
+		return "{}"
+	}
+	return string(buf)
+}
+
+// key returns the key of st in the JSON representation of Metrics.
+func (st DeviceStats) key() string {
+	if st.Bus < 0 {
+		return st.Addr.String()
+	}
+	return fmt.Sprintf("i2c-%d/%v", st.Bus, st.Addr)
+}
+
+// MultiTracer returns a tracer forwarding each transaction to all the
+// provided tracers, in order.
+func MultiTracer(ts ...Tracer) Tracer {
+	return multiTracer(append([]Tracer(nil), ts...))
+}
+
+type multiTracer []Tracer
+
+func (ts multiTracer) Trace(ev Event) {
+	for _, t := range ts {
+		t.Trace(ev)
+	}
+}
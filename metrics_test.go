@@ -0,0 +1,166 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/go-daq/smbus"
+	"github.com/go-daq/smbus/smbustest"
+)
+
+func TestMetrics(t *testing.T) {
+	c, bus := newSimConn(0x76, 0x77)
+	defer c.Close()
+	bus.Attach(0x50, &smbustest.Device{Busy: true})
+
+	m := smbus.NewMetrics()
+	var n int
+	c.SetTracer(smbus.MultiTracer(m, smbus.TracerFunc(func(smbus.Event) { n++ })))
+
+	for i := 0; i < 3; i++ {
+		_, err := c.ReadReg(0x76, 0xd0)
+		if err != nil {
+			t.Fatalf("read-reg error: %v", err)
+		}
+	}
+	err := c.WriteBlockData(0x77, 0x10, []byte{1, 2, 3, 4})
+	if err != nil {
+		t.Fatalf("write-block-data error: %v", err)
+	}
+	_, err = c.ReadWord(0x42, 0x00)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	_, err = c.ReadReg(0x50, 0x00)
+	if err == nil {
+		t.Fatalf("expected an error for a busy device")
+	}
+
+	if n != 6 {
+		t.Fatalf("invalid number of traced events: got=%d, want=6", n)
+	}
+
+	snap := m.Snapshot()
+	if len(snap) != 4 {
+		t.Fatalf("invalid number of devices: got=%d, want=4", len(snap))
+	}
+	for i, addr := range []smbus.Addr{0x42, 0x50, 0x76, 0x77} {
+		if snap[i].Addr != addr {
+			t.Fatalf("invalid device #%d: got=%v, want=%v", i, snap[i].Addr, addr)
+		}
+	}
+
+	nack := snap[0]
+	if got, want := nack.Errors[smbus.ErrClassNACK], uint64(1); got != want {
+		t.Fatalf("invalid number of NACK errors: got=%d, want=%d", got, want)
+	}
+
+	busy := snap[1]
+	if got, want := busy.Ops["read-reg"], uint64(1); got != want {
+		t.Fatalf("invalid number of busy read-reg: got=%d, want=%d", got, want)
+	}
+	if got, want := busy.Errors[smbus.ErrClassOther], uint64(1); got != want {
+		t.Fatalf("invalid number of in-use errors: got=%d, want=%d", got, want)
+	}
+
+	dev := snap[2]
+	if got, want := dev.Ops["read-reg"], uint64(3); got != want {
+		t.Fatalf("invalid number of read-reg: got=%d, want=%d", got, want)
+	}
+	if got, want := dev.BytesRead, uint64(3); got != want {
+		t.Fatalf("invalid number of bytes read: got=%d, want=%d", got, want)
+	}
+	if len(dev.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", dev.Errors)
+	}
+	if got, want := dev.Latency.Count, uint64(3); got != want {
+		t.Fatalf("invalid latency count: got=%d, want=%d", got, want)
+	}
+	var sum uint64
+	for _, v := range dev.Latency.Counts {
+		sum += v
+	}
+	if sum != dev.Latency.Count {
+		t.Fatalf("invalid latency histogram: %v", dev.Latency.Counts)
+	}
+
+	if got, want := snap[3].BytesWritten, uint64(4); got != want {
+		t.Fatalf("invalid number of bytes written: got=%d, want=%d", got, want)
+	}
+
+	var _ expvar.Var = m
+	var vars map[string]struct {
+		Ops    map[string]uint64 `json:"ops"`
+		Errors map[string]uint64 `json:"errors"`
+	}
+	err = json.Unmarshal([]byte(m.String()), &vars)
+	if err != nil {
+		t.Fatalf("could not decode expvar: %v", err)
+	}
+	if got, want := vars["0x76"].Ops["read-reg"], uint64(3); got != want {
+		t.Fatalf("invalid expvar read-reg: got=%d, want=%d", got, want)
+	}
+
+	m.Reset()
+	if len(m.Snapshot()) != 0 {
+		t.Fatalf("metrics should be empty after reset")
+	}
+}
+
+func TestMetricsBus(t *testing.T) {
+	m := smbus.NewMetrics()
+	m.Trace(smbus.Event{Op: "read-reg", Bus: 1, Addr: 0x76})
+	m.Trace(smbus.Event{Op: "read-reg", Bus: 0, Addr: 0x76})
+	m.Trace(smbus.Event{Op: "read-word", Bus: 0, Addr: 0x76})
+
+	snap := m.Snapshot()
+	if len(snap) != 2 {
+		t.Fatalf("invalid number of devices: got=%d, want=2", len(snap))
+	}
+	for i, want := range []struct {
+		bus int
+		ops uint64
+	}{{0, 2}, {1, 1}} {
+		st := snap[i]
+		var ops uint64
+		for _, n := range st.Ops {
+			ops += n
+		}
+		if st.Bus != want.bus || st.Addr != 0x76 || ops != want.ops {
+			t.Fatalf("invalid device #%d: got=(i2c-%d, %v, %d ops), want=(i2c-%d, 0x76, %d ops)",
+				i, st.Bus, st.Addr, ops, want.bus, want.ops,
+			)
+		}
+	}
+
+	var vars map[string]json.RawMessage
+	err := json.Unmarshal([]byte(m.String()), &vars)
+	if err != nil {
+		t.Fatalf("could not decode metrics: %v", err)
+	}
+	for _, key := range []string{"i2c-0/0x76", "i2c-1/0x76"} {
+		if _, ok := vars[key]; !ok {
+			t.Fatalf("missing device %q in %s", key, m.String())
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	m := smbus.NewMetricsWithBounds([]time.Duration{time.Millisecond, 10 * time.Millisecond})
+	for _, d := range []time.Duration{0, time.Millisecond, 2 * time.Millisecond, time.Second} {
+		m.Trace(smbus.Event{Op: "read-reg", Addr: 0x76, Duration: d})
+	}
+	h := m.Snapshot()[0].Latency
+	if got, want := h.Counts, []uint64{2, 1, 1}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("invalid histogram: got=%v, want=%v", got, want)
+	}
+	if got, want := h.Mean(), (3*time.Millisecond+time.Second)/4; got != want {
+		t.Fatalf("invalid mean: got=%v, want=%v", got, want)
+	}
+}
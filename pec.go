@@ -0,0 +1,114 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus
+
+import (
+	"fmt"
+)
+
+// PECError is returned when the Packet Error Code of a message received
+// from a device does not match its content.
+//
+// PEC errors are usually transient: callers may retry the operation.
+type PECError struct {
+	Addr Addr  // address of the device
+	Got  uint8 // received PEC (software PEC only)
+	Want uint8 // computed PEC (software PEC only)
+	Err  error // underlying error reported by the kernel, if any
+}
+
+func (e *PECError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("smbus: PEC mismatch from device %v: %v", e.Addr, e.Err)
+	}
+	return fmt.Sprintf("smbus: PEC mismatch from device %v (got=0x%02x, want=0x%02x)", e.Addr, e.Got, e.Want)
+}
+
+func (e *PECError) Unwrap() error { return e.Err }
+
+// PEC returns the SMBus Packet Error Code of the message in buf, computed
+// as a CRC-8 with polynomial x^8+x^2+x+1 (0x07).
+//
+// The message must include the address byte(s) of the transaction.
+func PEC(buf []byte) uint8 {
+	return crc8(0, buf)
+}
+
+func crc8(crc uint8, buf []byte) uint8 {
+	const poly = 0x07
+	for _, v := range buf {
+		crc ^= v
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ poly
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// SetPEC enables or disables Packet Error Checking.
+//
+// SMBus transactions are checked by the kernel.
+// Raw Read and Write transfers are checked in software.
+func (c *Conn) SetPEC(enable bool) error {
+	c.lock()
+	defer c.unlock()
+
+	var v uintptr
+	if enable {
+		if err := c.check("set-pec", c.slave, -1, FuncSMBusPEC); err != nil {
+			return err
+		}
+		v = 1
+	}
+	err := c.f.ioctl(i2cPEC, v)
+	if err != nil {
+		return c.wrap("set-pec", c.slave, -1, err)
+	}
+	c.pec = enable
+	return nil
+}
+
+// PEC returns whether Packet Error Checking is enabled.
+func (c *Conn) PEC() bool {
+	c.lock()
+	defer c.unlock()
+
+	return c.pec
+}
+
+func (c *Conn) writePEC(buf []byte) (int, error) {
+	data := make([]byte, len(buf)+1)
+	copy(data, buf)
+	crc := crc8(0, c.slave.wire(i2cSMBusWrite))
+	data[len(buf)] = crc8(crc, buf)
+
+	n, err := c.f.Write(data)
+	if n > len(buf) {
+		n = len(buf)
+	}
+	return n, err
+}
+
+func (c *Conn) readPEC(p []byte) (int, error) {
+	data := make([]byte, len(p)+1)
+	n, err := c.f.Read(data)
+	if err != nil {
+		return 0, err
+	}
+	if n != len(data) {
+		return 0, fmt.Errorf("smbus: short read (got=%d, want=%d)", n, len(data))
+	}
+
+	crc := crc8(0, c.slave.wire(i2cSMBusRead))
+	crc = crc8(crc, data[:len(p)])
+	if got := data[len(p)]; got != crc {
+		return 0, &PECError{Addr: c.slave, Got: got, Want: crc}
+	}
+	return copy(p, data[:len(p)]), nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus
+
+import (
+	"errors"
+	"runtime"
+	"unsafe"
+)
+
+const (
+	i2cRdWrMaxMsgs = 42 // I2C_RDWR_IOCTL_MAX_MSGS
+	i2cMsgMaxLen   = 1<<16 - 1
+)
+
+var (
+	errRdWrNoMsg   = errors.New("smbus: no message to transfer")
+	errRdWrMaxMsgs = errors.New("smbus: too many messages in transfer")
+	errRdWrMsgLen  = errors.New("smbus: message buffer too big")
+)
+
+// MsgFlags describes how a message of a combined transfer is sent.
+type MsgFlags uint16
+
+// Message flags.
+const (
+	MsgRead       MsgFlags = 0x0001 // read data, from slave to master
+	MsgTen        MsgFlags = 0x0010 // 10-bit slave address
+	MsgRecvLen    MsgFlags = 0x0400 // length is the first received byte
+	MsgNoReadAck  MsgFlags = 0x0800 // skip the acknowledge of read bytes
+	MsgIgnoreNAK  MsgFlags = 0x1000 // treat NACK from slave as ACK
+	MsgRevDirAddr MsgFlags = 0x2000 // toggle the read/write bit
+	MsgNoStart    MsgFlags = 0x4000 // no (repeated) start before this message
+	MsgStop       MsgFlags = 0x8000 // send a stop after this message
+)
+
+// Msg is a single i2c message of a combined transfer.
+// Messages to 10-bit addresses are sent with the MsgTen flag.
+type Msg struct {
+	Addr  Addr     // slave address
+	Flags MsgFlags // message flags
+	Buf   []byte   // data to write, or buffer to read into
+}
+
+// i2cMsg is the kernel representation of an i2c message (struct i2c_msg).
+type i2cMsg struct {
+	addr  uint16
+	flags uint16
+	len   uint16
+	buf   unsafe.Pointer
+}
+
+// i2cRdWrData is the argument of the I2C_RDWR ioctl (struct i2c_rdwr_ioctl_data).
+type i2cRdWrData struct {
+	msgs  unsafe.Pointer
+	nmsgs uint32
+}
+
+// Transfer executes the messages msgs as a single combined transaction:
+// messages are separated by repeated starts and the transaction is
+// terminated by a single stop.
+// Data read from the device is stored in the Buf field of each read message.
+func (c *Conn) Transfer(msgs ...Msg) error {
+	c.lock()
+	defer c.unlock()
+
+	switch {
+	case len(msgs) == 0:
+		return errRdWrNoMsg
+	case len(msgs) > i2cRdWrMaxMsgs:
+		return errRdWrMaxMsgs
+	}
+
+	if err := c.check("transfer", msgs[0].Addr, -1, FuncI2C); err != nil {
+		return err
+	}
+
+	kmsgs := make([]i2cMsg, len(msgs))
+	for i, msg := range msgs {
+		if len(msg.Buf) > i2cMsgMaxLen {
+			return errRdWrMsgLen
+		}
+		flags := msg.Flags
+		if msg.Addr.TenBit() {
+			if err := c.check("transfer", msg.Addr, -1, Func10BitAddr); err != nil {
+				return err
+			}
+			flags |= MsgTen
+		}
+		kmsgs[i] = i2cMsg{
+			addr:  msg.Addr.Value(),
+			flags: uint16(flags),
+			len:   uint16(len(msg.Buf)),
+		}
+		if len(msg.Buf) > 0 {
+			kmsgs[i].buf = unsafe.Pointer(&msg.Buf[0])
+		}
+	}
+
+	data := i2cRdWrData{
+		msgs:  unsafe.Pointer(&kmsgs[0]),
+		nmsgs: uint32(len(kmsgs)),
+	}
+	ev := c.begin("transfer", msgs[0].Addr, -1)
+	if ev != nil {
+		for _, msg := range msgs {
+			if msg.Flags&MsgRead == 0 {
+				ev.Write = append(ev.Write, msg.Buf...)
+			}
+		}
+	}
+	err := c.retry.do(c.context(), func() error {
+		return c.f.ioctlPtr(i2cRdWr, unsafe.Pointer(&data))
+	})
+	runtime.KeepAlive(msgs)
+	runtime.KeepAlive(kmsgs)
+	err = c.wrap("transfer", msgs[0].Addr, -1, err)
+	if ev != nil {
+		if err == nil {
+			for _, msg := range msgs {
+				if msg.Flags&MsgRead != 0 {
+					ev.Read = append(ev.Read, msg.Buf...)
+				}
+			}
+		}
+		c.end(ev, err)
+	}
+	return err
+}
+
+// Tx writes w to the device at address addr and then reads len(r) bytes
+// into r, with a repeated start and no stop in between.
+// Either w or r may be empty.
+func (c *Conn) Tx(addr uint8, w, r []byte) error {
+	msgs := make([]Msg, 0, 2)
+	if len(w) > 0 {
+		msgs = append(msgs, Msg{Addr: Addr7(addr), Buf: w})
+	}
+	if len(r) > 0 {
+		msgs = append(msgs, Msg{Addr: Addr7(addr), Flags: MsgRead, Buf: r})
+	}
+	return c.Transfer(msgs...)
+}
@@ -0,0 +1,224 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package regmap describes the registers and bitfields of SMBus devices.
+//
+// A Map caches the values of the non-volatile registers of a device.
+// Fields are modified in the cache and marked dirty; Sync writes all the
+// dirty registers to the device in a single pass.
+package regmap
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+
+	"github.com/go-daq/smbus"
+)
+
+// Register describes a register of a device.
+type Register struct {
+	Name string // name of the register, e.g. "CTRL_REG1"
+	Addr uint8  // address of the register, as sent on the bus
+
+	// Volatile registers may be modified by the device itself,
+	// e.g. status or data registers. Their values are never cached.
+	Volatile bool
+}
+
+// Field describes a bitfield of a register.
+type Field struct {
+	Name string // name of the field, e.g. "ODR"
+	Reg  uint8  // address of the register holding the field
+	Mask uint8  // bits of the register holding the field
+}
+
+// shift returns the position of the lowest bit of the field.
+func (f Field) shift() int {
+	return bits.TrailingZeros8(f.Mask)
+}
+
+// Map is a register map of a device on a bus.
+//
+// A Map is not safe for concurrent use.
+type Map struct {
+	*cache
+	bus smbus.Bus
+}
+
+// cache holds the state of a map, shared with its views.
+type cache struct {
+	addr    uint8
+	entries map[uint8]*entry
+	addrs   []uint8 // register addresses, in ascending order
+}
+
+type entry struct {
+	reg   Register
+	val   uint8 // cached value, including pending modifications
+	valid bool  // whether the bits of val outside of dirty reflect the device
+	dirty uint8 // bits modified since the last write to the device
+}
+
+// New returns a register map for the device at address addr on bus b,
+// with the provided registers.
+// The cache of the map is initially empty.
+func New(b smbus.Bus, addr uint8, registers ...Register) *Map {
+	m := &Map{
+		cache: &cache{
+			addr:    addr,
+			entries: make(map[uint8]*entry, len(registers)),
+		},
+		bus: b,
+	}
+	for _, reg := range registers {
+		if _, dup := m.entries[reg.Addr]; dup {
+			panic(fmt.Errorf("regmap: duplicate register 0x%02x", reg.Addr))
+		}
+		m.entries[reg.Addr] = &entry{reg: reg}
+		m.addrs = append(m.addrs, reg.Addr)
+	}
+	sort.Slice(m.addrs, func(i, j int) bool { return m.addrs[i] < m.addrs[j] })
+	return m
+}
+
+// WithBus returns a view of the map performing its operations through the
+// bus b, e.g. a view of the original bus honouring a context.
+// The view shares its cache with m.
+func (m *Map) WithBus(b smbus.Bus) *Map {
+	return &Map{cache: m.cache, bus: b}
+}
+
+func (m *Map) entry(reg uint8) (*entry, error) {
+	e, ok := m.entries[reg]
+	if !ok {
+		return nil, fmt.Errorf("regmap: unknown register 0x%02x", reg)
+	}
+	return e, nil
+}
+
+// Read returns the value of register reg, including the modifications
+// not yet written to the device.
+// Read only accesses the device for volatile or not yet cached registers.
+func (m *Map) Read(reg uint8) (uint8, error) {
+	e, err := m.entry(reg)
+	if err != nil {
+		return 0, err
+	}
+	return m.read(m.bus, e)
+}
+
+func (m *Map) read(b smbus.Bus, e *entry) (uint8, error) {
+	if e.valid && !e.reg.Volatile {
+		return e.val, nil
+	}
+	v, err := b.ReadReg(m.addr, e.reg.Addr)
+	if err != nil {
+		return 0, fmt.Errorf("regmap: could not read register %s: %w", e.reg.Name, err)
+	}
+	v = v&^e.dirty | e.val&e.dirty
+	if !e.reg.Volatile {
+		e.val = v
+		e.valid = true
+	}
+	return v, nil
+}
+
+// Write writes v to register reg, discarding any pending modification.
+func (m *Map) Write(reg, v uint8) error {
+	e, err := m.entry(reg)
+	if err != nil {
+		return err
+	}
+	return m.write(m.bus, e, v)
+}
+
+func (m *Map) write(b smbus.Bus, e *entry, v uint8) error {
+	err := b.WriteReg(m.addr, e.reg.Addr, v)
+	if err != nil {
+		e.valid = false
+		return fmt.Errorf("regmap: could not write register %s: %w", e.reg.Name, err)
+	}
+	e.val = v
+	e.valid = !e.reg.Volatile
+	e.dirty = 0
+	return nil
+}
+
+// Field returns the value of the field f, shifted down to bit 0.
+func (m *Map) Field(f Field) (uint8, error) {
+	v, err := m.Read(f.Reg)
+	if err != nil {
+		return 0, err
+	}
+	return (v & f.Mask) >> f.shift(), nil
+}
+
+// SetField sets the value of the field f to v, in the cache.
+// v is the value of the field shifted down to bit 0.
+// The field is marked dirty, unless it is known to already hold v.
+// The device is only modified by Sync.
+func (m *Map) SetField(f Field, v uint8) error {
+	e, err := m.entry(f.Reg)
+	if err != nil {
+		return err
+	}
+	if f.Mask == 0 {
+		return fmt.Errorf("regmap: empty mask for field %s", f.Name)
+	}
+	raw := v << f.shift()
+	if raw>>f.shift() != v || raw&^f.Mask != 0 {
+		return fmt.Errorf("regmap: value 0x%x overflows field %s", v, f.Name)
+	}
+	if e.valid && !e.reg.Volatile && e.val&f.Mask == raw {
+		return nil
+	}
+	e.val = e.val&^f.Mask | raw
+	e.dirty |= f.Mask
+	return nil
+}
+
+// Dirty reports whether some fields have not been written to the device yet.
+func (m *Map) Dirty() bool {
+	for _, e := range m.entries {
+		if e.dirty != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Sync writes all the registers holding dirty fields to the device,
+// in ascending address order.
+// The bits of these registers outside of the dirty fields are read back
+// from the device first if they are not cached.
+//
+// Sync holds the lock of the bus, if any, during the whole pass.
+func (m *Map) Sync() error {
+	return smbus.WithLock(m.bus, func(b smbus.Bus) error {
+		for _, addr := range m.addrs {
+			e := m.entries[addr]
+			if e.dirty == 0 {
+				continue
+			}
+			v, err := m.read(b, e)
+			if err != nil {
+				return err
+			}
+			err = m.write(b, e, v)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Invalidate drops the cached values of all registers.
+// Pending modifications are kept and written by the next Sync.
+func (m *Map) Invalidate() {
+	for _, e := range m.entries {
+		e.valid = false
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package regmap_test
+
+import (
+	"testing"
+
+	"github.com/go-daq/smbus/regmap"
+	"github.com/go-daq/smbus/smbustest"
+)
+
+const (
+	addr      = 0x5f
+	regCtrl   = 0x20
+	regStatus = 0x27
+	regConf   = 0x10
+)
+
+var (
+	fieldPD   = regmap.Field{Name: "PD", Reg: regCtrl, Mask: 0x80}
+	fieldODR  = regmap.Field{Name: "ODR", Reg: regCtrl, Mask: 0x03}
+	fieldAVGT = regmap.Field{Name: "AVGT", Reg: regConf, Mask: 0x38}
+	fieldRDY  = regmap.Field{Name: "RDY", Reg: regStatus, Mask: 0x01}
+)
+
+func newMap() (*regmap.Map, *smbustest.Device, *int, *int) {
+	bus := smbustest.New()
+	dev := &smbustest.Device{}
+	bus.Attach(addr, dev)
+
+	var reads, writes int
+	dev.ReadHook = func(dev *smbustest.Device, reg uint8) error {
+		reads++
+		return nil
+	}
+	dev.WriteHook = func(dev *smbustest.Device, reg, v uint8) error {
+		writes++
+		return nil
+	}
+
+	m := regmap.New(bus, addr,
+		regmap.Register{Name: "CTRL", Addr: regCtrl},
+		regmap.Register{Name: "CONF", Addr: regConf},
+		regmap.Register{Name: "STATUS", Addr: regStatus, Volatile: true},
+	)
+	return m, dev, &reads, &writes
+}
+
+func TestSync(t *testing.T) {
+	m, dev, reads, writes := newMap()
+	dev.MustSet(regCtrl, 0x04)
+	dev.MustSet(regConf, 0x03)
+
+	for _, f := range []struct {
+		f regmap.Field
+		v uint8
+	}{
+		{fieldPD, 1},
+		{fieldODR, 2},
+		{fieldAVGT, 5},
+	} {
+		err := m.SetField(f.f, f.v)
+		if err != nil {
+			t.Fatalf("set-field %s: %v", f.f.Name, err)
+		}
+	}
+	if *reads != 0 || *writes != 0 {
+		t.Fatalf("set-field should not access the device: reads=%d, writes=%d", *reads, *writes)
+	}
+	if !m.Dirty() {
+		t.Fatalf("map should be dirty")
+	}
+
+	err := m.Sync()
+	if err != nil {
+		t.Fatalf("sync error: %v", err)
+	}
+	if got, want := dev.Regs[regCtrl], uint8(0x86); got != want {
+		t.Fatalf("invalid CTRL: got=0x%02x, want=0x%02x", got, want)
+	}
+	if got, want := dev.Regs[regConf], uint8(0x2b); got != want {
+		t.Fatalf("invalid CONF: got=0x%02x, want=0x%02x", got, want)
+	}
+	if *reads != 2 || *writes != 2 {
+		t.Fatalf("invalid number of accesses: reads=%d, writes=%d", *reads, *writes)
+	}
+	if m.Dirty() {
+		t.Fatalf("map should be clean")
+	}
+
+	// cached registers: no read-back, and no write of unchanged fields.
+	*reads, *writes = 0, 0
+	err = m.SetField(fieldODR, 2)
+	if err != nil {
+		t.Fatalf("set-field error: %v", err)
+	}
+	if m.Dirty() {
+		t.Fatalf("unchanged field should not be dirty")
+	}
+	err = m.SetField(fieldODR, 1)
+	if err != nil {
+		t.Fatalf("set-field error: %v", err)
+	}
+	err = m.Sync()
+	if err != nil {
+		t.Fatalf("sync error: %v", err)
+	}
+	if got, want := dev.Regs[regCtrl], uint8(0x85); got != want {
+		t.Fatalf("invalid CTRL: got=0x%02x, want=0x%02x", got, want)
+	}
+	if *reads != 0 || *writes != 1 {
+		t.Fatalf("invalid number of accesses: reads=%d, writes=%d", *reads, *writes)
+	}
+
+	v, err := m.Field(fieldAVGT)
+	if err != nil {
+		t.Fatalf("field error: %v", err)
+	}
+	if v != 5 || *reads != 0 {
+		t.Fatalf("invalid cached field: got=%d (reads=%d), want=5 (reads=0)", v, *reads)
+	}
+
+	m.Invalidate()
+	dev.MustSet(regConf, 0x00)
+	v, err = m.Field(fieldAVGT)
+	if err != nil {
+		t.Fatalf("field error: %v", err)
+	}
+	if v != 0 || *reads != 1 {
+		t.Fatalf("invalid field after invalidation: got=%d (reads=%d), want=0 (reads=1)", v, *reads)
+	}
+}
+
+func TestVolatile(t *testing.T) {
+	m, dev, reads, _ := newMap()
+
+	for i, want := range []uint8{0, 1, 0} {
+		dev.MustSet(regStatus, want)
+		got, err := m.Field(fieldRDY)
+		if err != nil {
+			t.Fatalf("field error: %v", err)
+		}
+		if got != want {
+			t.Fatalf("invalid field: got=%d, want=%d", got, want)
+		}
+		if *reads != i+1 {
+			t.Fatalf("volatile register should not be cached")
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	m, _, _, _ := newMap()
+
+	err := m.SetField(fieldODR, 4)
+	if err == nil {
+		t.Fatalf("expected an overflow error")
+	}
+
+	err = m.SetField(regmap.Field{Name: "X", Reg: 0x42, Mask: 0x01}, 1)
+	if err == nil {
+		t.Fatalf("expected an unknown register error")
+	}
+
+	_, err = m.Read(0x42)
+	if err == nil {
+		t.Fatalf("expected an unknown register error")
+	}
+}
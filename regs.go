@@ -0,0 +1,189 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus
+
+import "encoding/binary"
+
+// Multi-byte register helpers.
+//
+// 16-bit values are transferred with SMBus word transactions.
+// 24- and 32-bit values are transferred with I2C block transactions,
+// starting at register reg: the device is expected to auto-increment
+// its register pointer.
+
+// ReadUint16BE reads the big-endian 16-bit value stored at registers
+// reg and reg+1 of the device at address addr.
+func ReadUint16BE(b Bus, addr, reg uint8) (uint16, error) {
+	v, err := b.ReadWord(addr, reg)
+	return v>>8 | v<<8, err
+}
+
+// ReadUint16LE reads the little-endian 16-bit value stored at registers
+// reg and reg+1 of the device at address addr.
+func ReadUint16LE(b Bus, addr, reg uint8) (uint16, error) {
+	return b.ReadWord(addr, reg)
+}
+
+// ReadInt16BE reads the big-endian signed 16-bit value stored at
+// registers reg and reg+1 of the device at address addr.
+func ReadInt16BE(b Bus, addr, reg uint8) (int16, error) {
+	v, err := ReadUint16BE(b, addr, reg)
+	return int16(v), err
+}
+
+// ReadInt16LE reads the little-endian signed 16-bit value stored at
+// registers reg and reg+1 of the device at address addr.
+func ReadInt16LE(b Bus, addr, reg uint8) (int16, error) {
+	v, err := ReadUint16LE(b, addr, reg)
+	return int16(v), err
+}
+
+// WriteUint16BE writes v as a big-endian 16-bit value to registers reg
+// and reg+1 of the device at address addr.
+func WriteUint16BE(b Bus, addr, reg uint8, v uint16) error {
+	return b.WriteWord(addr, reg, v>>8|v<<8)
+}
+
+// WriteUint16LE writes v as a little-endian 16-bit value to registers reg
+// and reg+1 of the device at address addr.
+func WriteUint16LE(b Bus, addr, reg uint8, v uint16) error {
+	return b.WriteWord(addr, reg, v)
+}
+
+// ReadUint24BE reads the big-endian 24-bit value stored at registers
+// reg to reg+2 of the device at address addr.
+func ReadUint24BE(b Bus, addr, reg uint8) (uint32, error) {
+	var buf [3]byte
+	err := b.ReadBlockData(addr, reg, buf[:])
+	if err != nil {
+		return 0, err
+	}
+	return uint32(buf[0])<<16 | uint32(buf[1])<<8 | uint32(buf[2]), nil
+}
+
+// ReadUint24LE reads the little-endian 24-bit value stored at registers
+// reg to reg+2 of the device at address addr.
+func ReadUint24LE(b Bus, addr, reg uint8) (uint32, error) {
+	var buf [3]byte
+	err := b.ReadBlockData(addr, reg, buf[:])
+	if err != nil {
+		return 0, err
+	}
+	return uint32(buf[2])<<16 | uint32(buf[1])<<8 | uint32(buf[0]), nil
+}
+
+// ReadInt24BE reads the big-endian signed 24-bit value stored at
+// registers reg to reg+2 of the device at address addr.
+// The value is sign-extended to 32 bits.
+func ReadInt24BE(b Bus, addr, reg uint8) (int32, error) {
+	v, err := ReadUint24BE(b, addr, reg)
+	return int32(v<<8) >> 8, err
+}
+
+// ReadInt24LE reads the little-endian signed 24-bit value stored at
+// registers reg to reg+2 of the device at address addr.
+// The value is sign-extended to 32 bits.
+func ReadInt24LE(b Bus, addr, reg uint8) (int32, error) {
+	v, err := ReadUint24LE(b, addr, reg)
+	return int32(v<<8) >> 8, err
+}
+
+// WriteUint24BE writes the 24 low bits of v as a big-endian value to
+// registers reg to reg+2 of the device at address addr.
+func WriteUint24BE(b Bus, addr, reg uint8, v uint32) error {
+	buf := []byte{byte(v >> 16), byte(v >> 8), byte(v)}
+	return b.WriteBlockData(addr, reg, buf)
+}
+
+// WriteUint24LE writes the 24 low bits of v as a little-endian value to
+// registers reg to reg+2 of the device at address addr.
+func WriteUint24LE(b Bus, addr, reg uint8, v uint32) error {
+	buf := []byte{byte(v), byte(v >> 8), byte(v >> 16)}
+	return b.WriteBlockData(addr, reg, buf)
+}
+
+// ReadUint32BE reads the big-endian 32-bit value stored at registers
+// reg to reg+3 of the device at address addr.
+func ReadUint32BE(b Bus, addr, reg uint8) (uint32, error) {
+	var buf [4]byte
+	err := b.ReadBlockData(addr, reg, buf[:])
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(buf[:]), nil
+}
+
+// ReadUint32LE reads the little-endian 32-bit value stored at registers
+// reg to reg+3 of the device at address addr.
+func ReadUint32LE(b Bus, addr, reg uint8) (uint32, error) {
+	var buf [4]byte
+	err := b.ReadBlockData(addr, reg, buf[:])
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(buf[:]), nil
+}
+
+// ReadInt32BE reads the big-endian signed 32-bit value stored at
+// registers reg to reg+3 of the device at address addr.
+func ReadInt32BE(b Bus, addr, reg uint8) (int32, error) {
+	v, err := ReadUint32BE(b, addr, reg)
+	return int32(v), err
+}
+
+// ReadInt32LE reads the little-endian signed 32-bit value stored at
+// registers reg to reg+3 of the device at address addr.
+func ReadInt32LE(b Bus, addr, reg uint8) (int32, error) {
+	v, err := ReadUint32LE(b, addr, reg)
+	return int32(v), err
+}
+
+// WriteUint32BE writes v as a big-endian 32-bit value to registers reg
+// to reg+3 of the device at address addr.
+func WriteUint32BE(b Bus, addr, reg uint8, v uint32) error {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return b.WriteBlockData(addr, reg, buf[:])
+}
+
+// WriteUint32LE writes v as a little-endian 32-bit value to registers reg
+// to reg+3 of the device at address addr.
+func WriteUint32LE(b Bus, addr, reg uint8, v uint32) error {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return b.WriteBlockData(addr, reg, buf[:])
+}
+
+// UpdateReg replaces the bits selected by mask in register reg of the
+// device at address addr with the corresponding bits of v.
+// The register is left untouched if its value would not change.
+//
+// UpdateReg holds the lock of the bus, if any, between the read and the
+// write of the register.
+func UpdateReg(b Bus, addr, reg, mask, v uint8) error {
+	return WithLock(b, func(b Bus) error {
+		old, err := b.ReadReg(addr, reg)
+		if err != nil {
+			return err
+		}
+		cur := old&^mask | v&mask
+		if cur == old {
+			return nil
+		}
+		return b.WriteReg(addr, reg, cur)
+	})
+}
+
+// SetBits sets the bits of register reg of the device at address addr
+// that are set in bits.
+func SetBits(b Bus, addr, reg, bits uint8) error {
+	return UpdateReg(b, addr, reg, bits, bits)
+}
+
+// ClearBits clears the bits of register reg of the device at address addr
+// that are set in bits.
+func ClearBits(b Bus, addr, reg, bits uint8) error {
+	return UpdateReg(b, addr, reg, bits, 0)
+}
@@ -0,0 +1,152 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus_test
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/go-daq/smbus"
+	"github.com/go-daq/smbus/sensor/bme280"
+	"github.com/go-daq/smbus/smbustest"
+)
+
+func TestRegs(t *testing.T) {
+	const addr = 0x76
+	bus := smbustest.New()
+	defer bus.Close()
+
+	dev := &smbustest.Device{}
+	dev.MustSet(0x10, 0x81, 0x02, 0x83, 0x04)
+	bus.Attach(addr, dev)
+
+	for _, tc := range []struct {
+		name string
+		read func() (int64, error)
+		want int64
+	}{
+		{"u16be", func() (int64, error) { v, err := smbus.ReadUint16BE(bus, addr, 0x10); return int64(v), err }, 0x8102},
+		{"u16le", func() (int64, error) { v, err := smbus.ReadUint16LE(bus, addr, 0x10); return int64(v), err }, 0x0281},
+		{"i16be", func() (int64, error) { v, err := smbus.ReadInt16BE(bus, addr, 0x10); return int64(v), err }, -0x7efe},
+		{"i16le", func() (int64, error) { v, err := smbus.ReadInt16LE(bus, addr, 0x11); return int64(v), err }, -0x7cfe},
+		{"u24be", func() (int64, error) { v, err := smbus.ReadUint24BE(bus, addr, 0x10); return int64(v), err }, 0x810283},
+		{"u24le", func() (int64, error) { v, err := smbus.ReadUint24LE(bus, addr, 0x10); return int64(v), err }, 0x830281},
+		{"i24be", func() (int64, error) { v, err := smbus.ReadInt24BE(bus, addr, 0x10); return int64(v), err }, 0x810283 - 0x1000000},
+		{"i24le", func() (int64, error) { v, err := smbus.ReadInt24LE(bus, addr, 0x11); return int64(v), err }, 0x048302},
+		{"u32be", func() (int64, error) { v, err := smbus.ReadUint32BE(bus, addr, 0x10); return int64(v), err }, 0x81028304},
+		{"u32le", func() (int64, error) { v, err := smbus.ReadUint32LE(bus, addr, 0x10); return int64(v), err }, 0x04830281},
+		{"i32be", func() (int64, error) { v, err := smbus.ReadInt32BE(bus, addr, 0x10); return int64(v), err }, 0x81028304 - 0x100000000},
+		{"i32le", func() (int64, error) { v, err := smbus.ReadInt32LE(bus, addr, 0x10); return int64(v), err }, 0x04830281},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.read()
+			if err != nil {
+				t.Fatalf("read error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("invalid value: got=%#x, want=%#x", got, tc.want)
+			}
+		})
+	}
+
+	for _, tc := range []struct {
+		name  string
+		write func() error
+		want  []uint8
+	}{
+		{"u16be", func() error { return smbus.WriteUint16BE(bus, addr, 0x20, 0x0102) }, []uint8{0x01, 0x02}},
+		{"u16le", func() error { return smbus.WriteUint16LE(bus, addr, 0x20, 0x0102) }, []uint8{0x02, 0x01}},
+		{"u24be", func() error { return smbus.WriteUint24BE(bus, addr, 0x20, 0x010203) }, []uint8{0x01, 0x02, 0x03}},
+		{"u24le", func() error { return smbus.WriteUint24LE(bus, addr, 0x20, 0x010203) }, []uint8{0x03, 0x02, 0x01}},
+		{"u32be", func() error { return smbus.WriteUint32BE(bus, addr, 0x20, 0x01020304) }, []uint8{0x01, 0x02, 0x03, 0x04}},
+		{"u32le", func() error { return smbus.WriteUint32LE(bus, addr, 0x20, 0x01020304) }, []uint8{0x04, 0x03, 0x02, 0x01}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dev.MustSet(0x20, 0, 0, 0, 0)
+			err := tc.write()
+			if err != nil {
+				t.Fatalf("write error: %v", err)
+			}
+			if got := dev.Regs[0x20 : 0x20+len(tc.want)]; string(got) != string(tc.want) {
+				t.Fatalf("invalid registers: got=%x, want=%x", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUpdateReg(t *testing.T) {
+	const addr = 0x76
+	c, bus := newSimConn(addr)
+	defer c.Close()
+
+	dev := bus.Device(addr)
+	dev.MustSet(0xf4, 0xa5)
+
+	writes := 0
+	dev.WriteHook = func(dev *smbustest.Device, reg, v uint8) error {
+		writes++
+		return nil
+	}
+
+	for _, tc := range []struct {
+		name   string
+		update func() error
+		want   uint8
+		writes int
+	}{
+		{"update", func() error { return smbus.UpdateReg(c, addr, 0xf4, 0x0f, 0x3c) }, 0xac, 1},
+		{"no-change", func() error { return smbus.UpdateReg(c, addr, 0xf4, 0x0f, 0x0c) }, 0xac, 0},
+		{"set-bits", func() error { return smbus.SetBits(c, addr, 0xf4, 0x03) }, 0xaf, 1},
+		{"clear-bits", func() error { return smbus.ClearBits(c, addr, 0xf4, 0xa0) }, 0x0f, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			writes = 0
+			err := tc.update()
+			if err != nil {
+				t.Fatalf("update error: %v", err)
+			}
+			if got := dev.Regs[0xf4]; got != tc.want {
+				t.Fatalf("invalid register: got=0x%02x, want=0x%02x", got, tc.want)
+			}
+			if writes != tc.writes {
+				t.Fatalf("invalid number of writes: got=%d, want=%d", writes, tc.writes)
+			}
+		})
+	}
+}
+
+func TestBME280NoI2CBlock(t *testing.T) {
+	c, bus := newSimConnFuncs(smbus.FuncI2C|smbus.FuncSMBusByteData|smbus.FuncSMBusWordData, bme280.I2CAddr)
+	defer c.Close()
+
+	dev := bus.Device(bme280.I2CAddr)
+	for i, v := range []int{
+		27504, 26435, -1000, // T1-T3
+		36477, -10685, 3024, 2855, 140, -7, 15500, -14600, 6000, // P1-P9
+	} {
+		binary.LittleEndian.PutUint16(dev.Regs[0x88+2*i:], uint16(v))
+	}
+	dev.MustSet(0xa1, 75)
+	dev.MustSet(0xe1, 0x6a, 0x01, 0x00, 0x13, 0x2d, 0x03, 0x1e)
+	dev.MustSet(0xf7, 0x65, 0x5a, 0xc0)
+	dev.MustSet(0xfa, 0x7e, 0xed, 0x00)
+	dev.MustSet(0xfd, 0x6e, 0x4a)
+
+	sensor, err := bme280.Open(c, bme280.I2CAddr, bme280.OpSample8)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	_, p, temp, err := sensor.Sample()
+	if err != nil {
+		t.Fatalf("sample error: %v", err)
+	}
+	if want := 25.08; math.Abs(temp-want) > 0.01 {
+		t.Fatalf("invalid temperature: got=%v, want=%v", temp, want)
+	}
+	if want := 100653.0; math.Abs(p-want) > 1 {
+		t.Fatalf("invalid pressure: got=%v, want=%v", p, want)
+	}
+}
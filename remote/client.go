@@ -0,0 +1,369 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package remote
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-daq/smbus"
+)
+
+// Client is a connection to a bus exported by a Server.
+// Client implements smbus.Bus.
+//
+// Like smbus.Conn, Read and Write target the device of the last operation,
+// or the device selected by SetAddr.
+//
+// Client is safe for concurrent use: requests are serialized.
+// Multi-step sequences should be performed with WithLock, which locks
+// the exported bus on the server.
+type Client struct {
+	*client
+	held bool            // whether the lock of client is held by the caller
+	ctx  context.Context // context of the operations, if any
+}
+
+// client is the state of a connection, shared by a Client and its views.
+type client struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	r      *bufio.Reader
+	addr   uint8 // address of the last device accessed, targeted by Read and Write
+	closed bool
+}
+
+var (
+	errBadResp     = errors.New("remote: malformed response")
+	errBlockTooBig = errors.New("remote: block too big")
+)
+
+// config holds configuration options for a Client.
+type config struct {
+	Token string
+	TLS   *tls.Config
+}
+
+// Token configures the token authenticating the client to the server.
+func Token(tok string) func(cfg *config) {
+	return func(cfg *config) {
+		cfg.Token = tok
+	}
+}
+
+// TLS configures Dial to connect to the server over TLS, with the provided
+// configuration. The client certificate, if any, authenticates the client.
+func TLS(c *tls.Config) func(cfg *config) {
+	return func(cfg *config) {
+		cfg.TLS = c
+	}
+}
+
+// Dial connects to the server at address on the named network, and
+// opens the bus exported under name.
+func Dial(network, address, name string, opts ...func(cfg *config)) (*Client, error) {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if cfg.TLS != nil {
+		conn, err = tls.Dial(network, address, cfg.TLS)
+	} else {
+		conn, err = net.Dial(network, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewClient(conn, name, opts...)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewClient opens the bus exported under name by the server at the other
+// end of conn. The returned client owns conn.
+// The TLS option is ignored: conn should already be a TLS connection.
+func NewClient(conn net.Conn, name string, opts ...func(cfg *config)) (*Client, error) {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if len(name) > 0xff {
+		return nil, fmt.Errorf("remote: bus name too long")
+	}
+
+	c := &Client{client: &client{conn: conn, r: bufio.NewReader(conn)}}
+	req := []byte{opHello, version, uint8(len(name))}
+	req = append(req, name...)
+	req = append(req, cfg.Token...)
+	_, err := c.roundTrip(req, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// WithContext returns a view of the client whose operations honour ctx:
+// operations fail with ctx.Err() when ctx is done before they start, and
+// the deadline of ctx, if any, bounds the exchange with the server.
+// An exchange interrupted by the deadline closes the client, as the late
+// response could otherwise be mistaken for the response to a later request.
+func (c *Client) WithContext(ctx context.Context) smbus.Bus {
+	if ctx == nil {
+		panic("remote: nil context")
+	}
+	return &Client{client: c.client, held: c.held, ctx: ctx}
+}
+
+// WithLock calls f with a view of the client holding the lock of the
+// exported bus on the server. Operations of other clients are blocked
+// until f returns.
+func (c *Client) WithLock(f func(b smbus.Bus) error) error {
+	c.lock()
+	defer c.unlock()
+
+	_, err := c.roundTrip([]byte{opLock}, 0, -1)
+	if err != nil {
+		return err
+	}
+	err = f(&Client{client: c.client, held: true, ctx: c.ctx})
+	_, uerr := c.roundTrip([]byte{opUnlock}, 0, -1)
+	if err == nil {
+		err = uerr
+	}
+	return err
+}
+
+func (c *Client) lock() {
+	if !c.held {
+		c.mu.Lock()
+	}
+}
+
+func (c *Client) unlock() {
+	if !c.held {
+		c.mu.Unlock()
+	}
+}
+
+// roundTrip sends the request req and returns the data of the response.
+// addr and reg describe the target of the request, for error reporting.
+// roundTrip closes the client when the exchange with the server fails.
+func (c *Client) roundTrip(req []byte, addr uint8, reg int) ([]byte, error) {
+	op := opNames[req[0]]
+	if c.closed {
+		return nil, &smbus.Error{Op: op, Bus: -1, Addr: smbus.Addr7(addr), Reg: reg, Err: os.ErrClosed}
+	}
+	if len(req) > maxFrame {
+		// nothing was sent: the connection is still usable.
+		return nil, &smbus.Error{Op: op, Bus: -1, Addr: smbus.Addr7(addr), Reg: reg, Err: errFrameTooBig}
+	}
+	if c.ctx != nil {
+		if err := c.ctx.Err(); err != nil {
+			return nil, &smbus.Error{Op: op, Bus: -1, Addr: smbus.Addr7(addr), Reg: reg, Err: err}
+		}
+	}
+
+	var deadline time.Time
+	if c.ctx != nil {
+		deadline, _ = c.ctx.Deadline()
+	}
+	err := c.conn.SetDeadline(deadline)
+	if err == nil {
+		err = writeFrame(c.conn, req)
+	}
+	var resp []byte
+	if err == nil {
+		resp, err = readFrame(c.r)
+	}
+	if err == nil && len(resp) == 0 {
+		err = errBadResp
+	}
+	if err != nil {
+		// the response to req may still be in flight: the connection
+		// can not be used anymore.
+		c.closed = true
+		c.conn.Close()
+		return nil, &smbus.Error{Op: op, Bus: -1, Addr: smbus.Addr7(addr), Reg: reg, Err: c.transportErr(err)}
+	}
+	if resp[0] != statusOK {
+		return nil, decodeErr(resp, op, addr, reg)
+	}
+	return resp[1:], nil
+}
+
+// transportErr returns the error describing the failure err of an
+// exchange with the server, reporting expired deadlines as such.
+func (c *Client) transportErr(err error) error {
+	if c.ctx == nil {
+		return err
+	}
+	switch cerr := c.ctx.Err(); {
+	case cerr == context.DeadlineExceeded, cerr == nil && errors.Is(err, os.ErrDeadlineExceeded):
+		return fmt.Errorf("%w: %w", smbus.ErrTimeout, context.DeadlineExceeded)
+	case cerr != nil:
+		return cerr
+	}
+	return err
+}
+
+func (c *Client) SetAddr(addr uint8) error {
+	c.lock()
+	defer c.unlock()
+
+	_, err := c.roundTrip([]byte{opSetAddr, addr}, addr, -1)
+	if err == nil {
+		c.addr = addr
+	}
+	return err
+}
+
+func (c *Client) Read(p []byte) (int, error) {
+	c.lock()
+	defer c.unlock()
+
+	if len(p) > maxFrame-2 {
+		return 0, errFrameTooBig
+	}
+	req := binary.BigEndian.AppendUint16([]byte{opRead, c.addr}, uint16(len(p)))
+	data, err := c.roundTrip(req, c.addr, -1)
+	if err != nil {
+		return 0, err
+	}
+	return copy(p, data), nil
+}
+
+func (c *Client) Write(p []byte) (int, error) {
+	c.lock()
+	defer c.unlock()
+
+	if len(p) > maxFrame-2 {
+		return 0, errFrameTooBig
+	}
+	data, err := c.roundTrip(append([]byte{opWrite, c.addr}, p...), c.addr, -1)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) != 2 {
+		return 0, errBadResp
+	}
+	return int(binary.BigEndian.Uint16(data)), nil
+}
+
+// Close closes the connection to the server.
+// The exported bus is not closed.
+func (c *Client) Close() error {
+	c.lock()
+	defer c.unlock()
+
+	if c.closed {
+		return os.ErrClosed
+	}
+	_, err := c.roundTrip([]byte{opClose}, c.addr, -1)
+	c.closed = true
+	if cerr := c.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (c *Client) ReadReg(addr, reg uint8) (uint8, error) {
+	c.lock()
+	defer c.unlock()
+
+	c.addr = addr
+	data, err := c.roundTrip([]byte{opReadReg, addr, reg}, addr, int(reg))
+	if err != nil {
+		return 0, err
+	}
+	if len(data) != 1 {
+		return 0, errBadResp
+	}
+	return data[0], nil
+}
+
+func (c *Client) WriteReg(addr, reg, v uint8) error {
+	c.lock()
+	defer c.unlock()
+
+	c.addr = addr
+	_, err := c.roundTrip([]byte{opWriteReg, addr, reg, v}, addr, int(reg))
+	return err
+}
+
+func (c *Client) ReadWord(addr, reg uint8) (uint16, error) {
+	c.lock()
+	defer c.unlock()
+
+	c.addr = addr
+	data, err := c.roundTrip([]byte{opReadWord, addr, reg}, addr, int(reg))
+	if err != nil {
+		return 0, err
+	}
+	if len(data) != 2 {
+		return 0, errBadResp
+	}
+	return uint16(data[0]) | uint16(data[1])<<8, nil
+}
+
+func (c *Client) WriteWord(addr, reg uint8, v uint16) error {
+	c.lock()
+	defer c.unlock()
+
+	c.addr = addr
+	_, err := c.roundTrip([]byte{opWriteWord, addr, reg, uint8(v), uint8(v >> 8)}, addr, int(reg))
+	return err
+}
+
+func (c *Client) ReadBlockData(addr, reg uint8, buf []byte) error {
+	c.lock()
+	defer c.unlock()
+
+	if len(buf) > 0xff {
+		return errBlockTooBig
+	}
+	c.addr = addr
+	data, err := c.roundTrip([]byte{opReadBlock, addr, reg, uint8(len(buf))}, addr, int(reg))
+	if err != nil {
+		return err
+	}
+	if len(data) != len(buf) {
+		return errBadResp
+	}
+	copy(buf, data)
+	return nil
+}
+
+func (c *Client) WriteBlockData(addr, reg uint8, buf []byte) error {
+	c.lock()
+	defer c.unlock()
+
+	if len(buf) > 0xff {
+		return errBlockTooBig
+	}
+	c.addr = addr
+	_, err := c.roundTrip(append([]byte{opWriteBlock, addr, reg}, buf...), addr, int(reg))
+	return err
+}
+
+var (
+	_ smbus.Bus    = (*Client)(nil)
+	_ smbus.Locker = (*Client)(nil)
+)
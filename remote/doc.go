@@ -0,0 +1,77 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package remote exports SMBus adapters over the network.
+//
+// A Server exports one or more buses under a name. A Client connects to
+// one of these buses and implements smbus.Bus: drivers written against
+// smbus.Bus work unchanged over the network.
+//
+// # Protocol
+//
+// Client and server exchange frames over a stream connection (e.g. TCP).
+// Each frame is a 4-bytes big-endian length N, followed by N bytes of body.
+// N may not exceed 65536.
+//
+// The client sends request frames and waits for the response frame of
+// each request before sending the next one.
+// The body of a request is an operation code byte, followed by the
+// arguments of the operation:
+//
+//	code  operation         arguments               response data
+//	0x01  hello             version, n, name, token -
+//	0x02  set-addr          addr                    -
+//	0x03  read              addr, n (uint16)        data
+//	0x04  write             addr, data              n (uint16)
+//	0x05  read-reg          addr, reg               v
+//	0x06  write-reg         addr, reg, v            -
+//	0x07  read-word         addr, reg               lo, hi
+//	0x08  write-word        addr, reg, lo, hi       -
+//	0x09  read-block-data   addr, reg, n            data
+//	0x0a  write-block-data  addr, reg, data         -
+//	0x0b  lock              -                       -
+//	0x0c  unlock            -                       -
+//	0x0d  close             -                       -
+//
+// Unless noted otherwise, arguments are single bytes. Multi-byte integers
+// are big-endian. Words are sent in bus order, low byte first.
+// Data extends until the end of the body.
+//
+// The first request of a connection must be hello, with the protocol
+// version (1), the length n of the name of the exported bus to use,
+// that name, and an optional authentication token, extending until the
+// end of the body.
+//
+// The lock request gives the connection exclusive access to the bus, until
+// unlock or the end of the connection. Other requests are atomic.
+// The server releases a lock held for more than its LockTimeout: the
+// following requests of the connection then fail, until unlock.
+// The close request ends the connection; it does not close the exported bus.
+//
+// The body of a response is a status byte, 0 for success and 1 for
+// failure. A success status is followed by the response data of the
+// operation. A failure status is followed by an error kind byte, a
+// 4-bytes errno (0 if none) and the error message:
+//
+//	kind  meaning
+//	0x00  other error
+//	0x01  system error, as described by errno (e.g. ENXIO)
+//	0x02  operation unsupported by the adapter (smbus.ErrUnsupported)
+//	0x03  operation denied by the access policy (ErrDenied)
+//
+// # Access control
+//
+// A server with a non-nil Policies map restricts the operations of each
+// client to the rules of its policy, looked up by client identity.
+// The identity of a client is the common name of its verified TLS client
+// certificate, if any. Otherwise, it is the identity associated with the
+// token of the hello request in the Tokens map of the server.
+// Clients with neither are anonymous, with the empty identity.
+// Clients without a policy are rejected.
+// Locking the bus requires the Lock right, as it blocks all the other
+// users of the bus.
+//
+// Denied operations fail with ErrDenied and are logged to the audit logger
+// of the server.
+package remote
@@ -0,0 +1,193 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrDenied reports an operation denied by the access policy of the server.
+var ErrDenied = errors.New("remote: access denied")
+
+// Access is a set of access rights.
+type Access uint8
+
+const (
+	Read      Access = 1 << iota // read registers, or receive data
+	Write                        // write registers, or send data
+	Lock                         // lock the bus for exclusive access
+	ReadWrite = Read | Write
+)
+
+// accessNames lists the letters of the access rights, in canonical order.
+var accessNames = []struct {
+	c byte
+	a Access
+}{
+	{'r', Read},
+	{'w', Write},
+	{'l', Lock},
+}
+
+func (a Access) String() string {
+	if a == 0 {
+		return "none"
+	}
+	if a&^(ReadWrite|Lock) != 0 {
+		return fmt.Sprintf("Access(%d)", uint8(a))
+	}
+	var o []byte
+	for _, v := range accessNames {
+		if a&v.a != 0 {
+			o = append(o, v.c)
+		}
+	}
+	return string(o)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (a Access) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// Valid values are combinations of the letters "r", "w" and "l", for
+// Read, Write and Lock, e.g. "rw" or "rwl".
+func (a *Access) UnmarshalText(p []byte) error {
+	var v Access
+loop:
+	for _, c := range []byte(strings.ToLower(string(p))) {
+		for _, name := range accessNames {
+			if name.c == c && v&name.a == 0 {
+				v |= name.a
+				continue loop
+			}
+		}
+		return fmt.Errorf("remote: invalid access %q", p)
+	}
+	if v == 0 {
+		return fmt.Errorf("remote: invalid access %q", p)
+	}
+	*a = v
+	return nil
+}
+
+// Bytes is a list of addresses or registers.
+// Bytes is marshaled to JSON as an array of numbers, rather than as a
+// base64 string.
+type Bytes []uint8
+
+// MarshalJSON implements json.Marshaler.
+func (p Bytes) MarshalJSON() ([]byte, error) {
+	if p == nil {
+		return []byte("null"), nil
+	}
+	vs := make([]int, len(p))
+	for i, v := range p {
+		vs[i] = int(v)
+	}
+	return json.Marshal(vs)
+}
+
+// Rule grants access rights to registers of devices.
+type Rule struct {
+	// Addrs lists the addresses of the devices covered by the rule.
+	// A nil Addrs, encoded as a null or missing JSON field, covers all
+	// devices. An empty Addrs covers none.
+	Addrs Bytes `json:"addrs"`
+
+	// Regs lists the registers covered by the rule.
+	// A nil Regs, encoded as a null or missing JSON field, covers all
+	// registers, as well as raw reads and writes that do not designate a
+	// register. An empty Regs covers none.
+	Regs Bytes `json:"regs"`
+
+	// Access lists the rights granted by the rule.
+	// The Lock right covers the whole bus: it is granted by any rule
+	// holding it, whatever its Addrs and Regs.
+	Access Access `json:"access"`
+}
+
+func (r Rule) covers(addr uint8, reg int) bool {
+	if r.Addrs != nil && !contains(r.Addrs, addr) {
+		return false
+	}
+	if r.Regs == nil {
+		return true
+	}
+	return reg >= 0 && contains(r.Regs, uint8(reg))
+}
+
+func contains(vs []uint8, v uint8) bool {
+	for _, x := range vs {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy is the access policy of a client.
+// Operations not granted by any rule are denied.
+type Policy struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Allowed reports whether the policy grants the access rights a on the n
+// consecutive registers starting at reg of the device at address addr.
+// A negative reg designates a raw read or write, without register.
+//
+// Multi-byte operations are assumed to auto-increment the register
+// address: all the n registers must be granted.
+func (p *Policy) Allowed(addr uint8, reg, n int, a Access) bool {
+	if reg < 0 {
+		return p.allowed(addr, -1, a)
+	}
+	if n < 1 {
+		n = 1 // the register is designated, even without data.
+	}
+	for i := 0; i < n; i++ {
+		if reg+i > 0xff || !p.allowed(addr, reg+i, a) {
+			return false
+		}
+	}
+	return true
+}
+
+// allowed reports whether the access rights a on register reg are
+// granted, possibly by several rules.
+func (p *Policy) allowed(addr uint8, reg int, a Access) bool {
+	var got Access
+	for _, r := range p.Rules {
+		if r.covers(addr, reg) {
+			got |= r.Access
+		}
+	}
+	return got&a == a
+}
+
+// lockable reports whether the policy grants the right to lock the bus.
+func (p *Policy) lockable() bool {
+	for _, r := range p.Rules {
+		if r.Access&Lock != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// addressable reports whether the policy grants some access to the
+// device at address addr.
+func (p *Policy) addressable(addr uint8) bool {
+	for _, r := range p.Rules {
+		if r.Access != 0 && (r.Addrs == nil || contains(r.Addrs, addr)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package remote
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+
+	"github.com/go-daq/smbus"
+)
+
+const (
+	version  = 1
+	maxFrame = 1 << 16
+)
+
+// operation codes
+const (
+	opHello      = 0x01
+	opSetAddr    = 0x02
+	opRead       = 0x03
+	opWrite      = 0x04
+	opReadReg    = 0x05
+	opWriteReg   = 0x06
+	opReadWord   = 0x07
+	opWriteWord  = 0x08
+	opReadBlock  = 0x09
+	opWriteBlock = 0x0a
+	opLock       = 0x0b
+	opUnlock     = 0x0c
+	opClose      = 0x0d
+)
+
+var opNames = map[byte]string{
+	opHello:      "hello",
+	opSetAddr:    "set-addr",
+	opRead:       "read",
+	opWrite:      "write",
+	opReadReg:    "read-reg",
+	opWriteReg:   "write-reg",
+	opReadWord:   "read-word",
+	opWriteWord:  "write-word",
+	opReadBlock:  "read-block-data",
+	opWriteBlock: "write-block-data",
+	opLock:       "lock",
+	opUnlock:     "unlock",
+	opClose:      "close",
+}
+
+// reqArgs holds the minimal number of argument bytes of the requests.
+var reqArgs = map[byte]int{
+	opHello:      2,
+	opSetAddr:    1,
+	opRead:       3,
+	opWrite:      1,
+	opReadReg:    2,
+	opWriteReg:   3,
+	opReadWord:   2,
+	opWriteWord:  4,
+	opReadBlock:  3,
+	opWriteBlock: 2,
+}
+
+// response status
+const (
+	statusOK  = 0x00
+	statusErr = 0x01
+)
+
+// error kinds
+const (
+	kindOther       = 0x00
+	kindErrno       = 0x01
+	kindUnsupported = 0x02
+	kindDenied      = 0x03
+)
+
+var (
+	errFrameTooBig = errors.New("remote: frame too big")
+	errShortFrame  = errors.New("remote: short frame")
+)
+
+func writeFrame(w io.Writer, body []byte) error {
+	if len(body) > maxFrame {
+		return errFrameTooBig
+	}
+	buf := make([]byte, 4+len(body))
+	binary.BigEndian.PutUint32(buf, uint32(len(body)))
+	copy(buf[4:], body)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	var hdr [4]byte
+	_, err := io.ReadFull(r, hdr[:])
+	if err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n > maxFrame {
+		return nil, errFrameTooBig
+	}
+	body := make([]byte, n)
+	_, err = io.ReadFull(r, body)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// encodeErr returns the body of the failure response describing err.
+func encodeErr(err error) []byte {
+	var (
+		kind  byte = kindOther
+		errno syscall.Errno
+	)
+	switch {
+	case errors.Is(err, ErrDenied):
+		kind = kindDenied
+	case errors.Is(err, smbus.ErrUnsupported):
+		kind = kindUnsupported
+	case errors.As(err, &errno):
+		kind = kindErrno
+	}
+	body := make([]byte, 6, 6+len(err.Error()))
+	body[0] = statusErr
+	body[1] = kind
+	binary.BigEndian.PutUint32(body[2:], uint32(errno))
+	return append(body, err.Error()...)
+}
+
+// decodeErr returns the error described by the body of a failure response,
+// for the operation op on the register reg of the device at address addr.
+func decodeErr(body []byte, op string, addr uint8, reg int) error {
+	if len(body) < 6 {
+		return errShortFrame
+	}
+	var (
+		kind  = body[1]
+		errno = syscall.Errno(binary.BigEndian.Uint32(body[2:]))
+		msg   = string(body[6:])
+		err   error
+	)
+	switch kind {
+	case kindErrno:
+		err = errno
+	case kindUnsupported:
+		err = fmt.Errorf("%w: %s", smbus.ErrUnsupported, msg)
+	case kindDenied:
+		err = ErrDenied
+		if msg != ErrDenied.Error() {
+			err = fmt.Errorf("%w: %s", ErrDenied, strings.TrimPrefix(msg, ErrDenied.Error()+": "))
+		}
+	default:
+		err = fmt.Errorf("remote: %s", msg)
+	}
+	return &smbus.Error{Op: op, Bus: -1, Addr: smbus.Addr7(addr), Reg: reg, Err: err}
+}
@@ -0,0 +1,789 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package remote_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-daq/smbus"
+	"github.com/go-daq/smbus/remote"
+	"github.com/go-daq/smbus/sensor/bme280"
+	"github.com/go-daq/smbus/smbustest"
+)
+
+func newServer(t *testing.T, srv *remote.Server) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go srv.Serve(l)
+	return l.Addr().String()
+}
+
+func newBME280() *smbustest.Bus {
+	bus := smbustest.New()
+	dev := &smbustest.Device{}
+	for i, v := range []int{
+		27504, 26435, -1000, // T1-T3
+		36477, -10685, 3024, 2855, 140, -7, 15500, -14600, 6000, // P1-P9
+	} {
+		binary.LittleEndian.PutUint16(dev.Regs[0x88+2*i:], uint16(v))
+	}
+	dev.MustSet(0xa1, 75)
+	dev.MustSet(0xe1, 0x6a, 0x01, 0x00, 0x13, 0x2d, 0x03, 0x1e)
+	dev.MustSet(0xf7, 0x65, 0x5a, 0xc0)
+	dev.MustSet(0xfa, 0x7e, 0xed, 0x00)
+	dev.MustSet(0xfd, 0x6e, 0x4a)
+	bus.Attach(bme280.I2CAddr, dev)
+	return bus
+}
+
+func TestBME280(t *testing.T) {
+	srv := remote.NewServer()
+	srv.Export("i2c-1", newBME280())
+	addr := newServer(t, srv)
+
+	c, err := remote.Dial("tcp", addr, "i2c-1")
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer c.Close()
+
+	sensor, err := bme280.Open(c, bme280.I2CAddr, bme280.OpSample8)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	_, p, temp, err := sensor.Sample()
+	if err != nil {
+		t.Fatalf("sample error: %v", err)
+	}
+	if want := 25.08; math.Abs(temp-want) > 0.01 {
+		t.Fatalf("invalid temperature: got=%v, want=%v", temp, want)
+	}
+	if want := 100653.0; math.Abs(p-want) > 1 {
+		t.Fatalf("invalid pressure: got=%v, want=%v", p, want)
+	}
+}
+
+func TestClient(t *testing.T) {
+	bus := smbustest.New()
+	dev := &smbustest.Device{}
+	bus.Attach(0x76, dev)
+
+	srv := remote.NewServer()
+	srv.Export("sim", bus)
+	addr := newServer(t, srv)
+
+	_, err := remote.Dial("tcp", addr, "unknown")
+	if err == nil {
+		t.Fatalf("expected an error for an unknown bus")
+	}
+
+	c, err := remote.Dial("tcp", addr, "sim")
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer c.Close()
+
+	err = c.WriteWord(0x76, 0x10, 0x1234)
+	if err != nil {
+		t.Fatalf("write-word error: %v", err)
+	}
+	v, err := c.ReadWord(0x76, 0x10)
+	if err != nil {
+		t.Fatalf("read-word error: %v", err)
+	}
+	if v != 0x1234 {
+		t.Fatalf("invalid word: got=0x%x, want=0x1234", v)
+	}
+
+	err = c.WriteBlockData(0x76, 0x20, []byte{1, 2, 3})
+	if err != nil {
+		t.Fatalf("write-block-data error: %v", err)
+	}
+	err = c.SetAddr(0x76)
+	if err != nil {
+		t.Fatalf("set-addr error: %v", err)
+	}
+	n, err := c.Write([]byte{0x21})
+	if err != nil || n != 1 {
+		t.Fatalf("write error: n=%d, err=%v", n, err)
+	}
+	buf := make([]byte, 2)
+	n, err = c.Read(buf)
+	if err != nil || n != 2 {
+		t.Fatalf("read error: n=%d, err=%v", n, err)
+	}
+	if buf[0] != 2 || buf[1] != 3 {
+		t.Fatalf("invalid data: got=%v, want=[2 3]", buf)
+	}
+
+	// like with smbus.Conn, raw reads and writes target the device
+	// of the last operation.
+	other := &smbustest.Device{}
+	other.MustSet(0x05, 0x55)
+	bus.Attach(0x40, other)
+	_, err = c.ReadReg(0x40, 0x00)
+	if err != nil {
+		t.Fatalf("read-reg error: %v", err)
+	}
+	_, err = c.Write([]byte{0x05})
+	if err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	_, err = c.Read(buf[:1])
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	if buf[0] != 0x55 {
+		t.Fatalf("invalid data: got=0x%02x, want=0x55", buf[0])
+	}
+
+	_, err = c.ReadReg(0x42, 0x00)
+	if !errors.Is(err, smbus.ErrNACK) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, smbus.ErrNACK)
+	}
+	var e *smbus.Error
+	if !errors.As(err, &e) || e.Op != "read-reg" || e.Addr != 0x42 {
+		t.Fatalf("invalid error: %#v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = smbus.WithContext(ctx, c).ReadReg(0x76, 0x10)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, context.Canceled)
+	}
+
+	// invalid arguments do not close the client.
+	_, err = c.Write(make([]byte, 1<<16))
+	if err == nil {
+		t.Fatalf("expected an error for an oversized write")
+	}
+	err = c.WriteBlockData(0x76, 0x20, make([]byte, 256))
+	if err == nil {
+		t.Fatalf("expected an error for an oversized block")
+	}
+	_, err = c.ReadReg(0x76, 0x10)
+	if err != nil {
+		t.Fatalf("read-reg after invalid arguments error: %v", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	bus := smbustest.New()
+	dev := &smbustest.Device{
+		ReadHook: func(dev *smbustest.Device, reg uint8) error {
+			if reg == 0x10 {
+				time.Sleep(100 * time.Millisecond)
+			}
+			return nil
+		},
+	}
+	dev.MustSet(0x10, 0xaa)
+	dev.MustSet(0x20, 0xbb)
+	bus.Attach(0x76, dev)
+
+	srv := remote.NewServer()
+	srv.Export("sim", bus)
+	addr := newServer(t, srv)
+
+	c, err := remote.Dial("tcp", addr, "sim")
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = smbus.WithContext(ctx, c).ReadReg(0x76, 0x10)
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, smbus.ErrTimeout) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, context.DeadlineExceeded)
+	}
+	var e *smbus.Error
+	if !errors.As(err, &e) || e.Op != "read-reg" || e.Addr != 0x76 || e.Reg != 0x10 {
+		t.Fatalf("invalid error: %#v", err)
+	}
+
+	// the response to the timed out request must not be mistaken for
+	// the response to the next one.
+	v, err := c.ReadReg(0x76, 0x20)
+	if !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("invalid error: got=%v (v=0x%02x), want=%v", err, v, os.ErrClosed)
+	}
+}
+
+func TestLock(t *testing.T) {
+	bus := smbustest.New()
+	bus.Attach(0x76, &smbustest.Device{})
+
+	srv := remote.NewServer()
+	srv.Export("sim", bus)
+	addr := newServer(t, srv)
+
+	c1, err := remote.Dial("tcp", addr, "sim")
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer c1.Close()
+	c2, err := remote.Dial("tcp", addr, "sim")
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer c2.Close()
+
+	var (
+		mu    sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+	locked := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := c1.WithLock(func(b smbus.Bus) error {
+			close(locked)
+			time.Sleep(50 * time.Millisecond)
+			mu.Lock()
+			order = append(order, "c1")
+			mu.Unlock()
+			return b.WriteReg(0x76, 0x00, 1)
+		})
+		if err != nil {
+			t.Errorf("with-lock error: %v", err)
+		}
+	}()
+
+	<-locked
+	err = c2.WriteReg(0x76, 0x00, 2)
+	if err != nil {
+		t.Fatalf("write-reg error: %v", err)
+	}
+	mu.Lock()
+	order = append(order, "c2")
+	mu.Unlock()
+	wg.Wait()
+
+	if len(order) != 2 || order[0] != "c1" {
+		t.Fatalf("lock not honoured: %v", order)
+	}
+	if got := bus.Device(0x76).Regs[0]; got != 2 {
+		t.Fatalf("invalid register: got=%d, want=2", got)
+	}
+}
+
+// lockBus is a bus implementing smbus.Locker.
+type lockBus struct {
+	*smbustest.Bus
+	mu sync.Mutex
+}
+
+func (b *lockBus) WithLock(f func(b smbus.Bus) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return f(b.Bus)
+}
+
+func TestLockLocal(t *testing.T) {
+	bus := &lockBus{Bus: smbustest.New()}
+	bus.Attach(0x76, &smbustest.Device{})
+
+	srv := remote.NewServer()
+	srv.Export("sim", bus)
+	addr := newServer(t, srv)
+
+	c, err := remote.Dial("tcp", addr, "sim")
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer c.Close()
+
+	incr := func(b smbus.Bus) error {
+		v, err := b.ReadReg(0x76, 0x00)
+		if err != nil {
+			return err
+		}
+		time.Sleep(20 * time.Millisecond)
+		return b.WriteReg(0x76, 0x00, v+1)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := c.WithLock(incr); err != nil {
+				t.Errorf("remote with-lock error: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := smbus.WithLock(bus, incr); err != nil {
+				t.Errorf("local with-lock error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := bus.Device(0x76).Regs[0]; got != 8 {
+		t.Fatalf("read-modify-write sequences interleaved: got=%d, want=8", got)
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	bus := smbustest.New()
+	bus.Attach(0x76, &smbustest.Device{})
+
+	srv := remote.NewServer()
+	srv.LockTimeout = 50 * time.Millisecond
+	srv.Export("sim", bus)
+	addr := newServer(t, srv)
+
+	c1, err := remote.Dial("tcp", addr, "sim")
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer c1.Close()
+	c2, err := remote.Dial("tcp", addr, "sim")
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer c2.Close()
+
+	err = c1.WithLock(func(b smbus.Bus) error {
+		err := c2.WriteReg(0x76, 0x00, 2) // blocked until the lock expires
+		if err != nil {
+			t.Errorf("write-reg error: %v", err)
+		}
+		return b.WriteReg(0x76, 0x00, 1)
+	})
+	if err == nil {
+		t.Fatalf("expected an error for an expired lock")
+	}
+	if got := bus.Device(0x76).Regs[0]; got != 2 {
+		t.Fatalf("write after lock expiry reached the device: got=%d, want=2", got)
+	}
+
+	err = c1.WriteReg(0x76, 0x00, 3)
+	if err != nil {
+		t.Fatalf("write-reg after unlock error: %v", err)
+	}
+}
+
+func TestPolicyLock(t *testing.T) {
+	bus := smbustest.New()
+	bus.Attach(0x76, &smbustest.Device{})
+
+	var admin remote.Rule
+	err := json.Unmarshal([]byte(`{"access": "rwl"}`), &admin)
+	if err != nil {
+		t.Fatalf("could not decode rule: %v", err)
+	}
+	if got, want := admin.Access, remote.ReadWrite|remote.Lock; got != want {
+		t.Fatalf("invalid access: got=%v, want=%v", got, want)
+	}
+
+	audit := new(bytes.Buffer)
+	srv := remote.NewServer()
+	srv.AuditLogger = slog.New(slog.NewTextHandler(audit, nil))
+	srv.Tokens = map[string]string{"s3cr3t": "admin"}
+	srv.Policies = map[string]*remote.Policy{
+		"":      {Rules: []remote.Rule{{Access: remote.Read}}},
+		"admin": {Rules: []remote.Rule{admin}},
+	}
+	srv.Export("sim", bus)
+	addr := newServer(t, srv)
+
+	reader, err := remote.Dial("tcp", addr, "sim")
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer reader.Close()
+
+	called := false
+	err = reader.WithLock(func(b smbus.Bus) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, remote.ErrDenied) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, remote.ErrDenied)
+	}
+	if called {
+		t.Fatalf("locked section called without the lock")
+	}
+	if log := audit.String(); !strings.Contains(log, "op=lock bus=sim") {
+		t.Fatalf("denied lock not audited: %q", log)
+	}
+
+	_, err = reader.ReadReg(0x76, 0x00)
+	if err != nil {
+		t.Fatalf("read-reg error: %v", err)
+	}
+
+	c, err := remote.Dial("tcp", addr, "sim", remote.Token("s3cr3t"))
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer c.Close()
+	err = c.WithLock(func(b smbus.Bus) error {
+		return b.WriteReg(0x76, 0x00, 1)
+	})
+	if err != nil {
+		t.Fatalf("with-lock error: %v", err)
+	}
+}
+
+func TestPolicyAllowed(t *testing.T) {
+	var p remote.Policy
+	err := json.Unmarshal([]byte(`{"rules": [
+		{"addrs": [118], "regs": [16, 17, 18], "access": "r"},
+		{"addrs": [118], "regs": [17], "access": "w"},
+		{"addrs": [119], "access": "rw"}
+	]}`), &p)
+	if err != nil {
+		t.Fatalf("could not decode policy: %v", err)
+	}
+
+	raw, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("could not encode policy: %v", err)
+	}
+	if got, want := string(raw), `{"rules":[{"addrs":[118],"regs":[16,17,18],"access":"r"},{"addrs":[118],"regs":[17],"access":"w"},{"addrs":[119],"regs":null,"access":"rw"}]}`; got != want {
+		t.Fatalf("invalid policy encoding:\ngot= %s\nwant=%s", got, want)
+	}
+
+	for _, tc := range []struct {
+		addr uint8
+		reg  int
+		n    int
+		a    remote.Access
+		want bool
+	}{
+		{0x76, 0x10, 1, remote.Read, true},
+		{0x76, 0x10, 3, remote.Read, true},
+		{0x76, 0x10, 4, remote.Read, false},
+		{0x76, 0x10, 1, remote.Write, false},
+		{0x76, 0x11, 1, remote.ReadWrite, true},
+		{0x76, 0x11, 0, remote.Write, true},
+		{0x76, 0x12, 0, remote.Write, false},
+		{0x76, -1, 0, remote.Read, false},
+		{0x77, 0xff, 1, remote.ReadWrite, true},
+		{0x77, 0xff, 2, remote.Read, false},
+		{0x77, -1, 0, remote.Write, true},
+		{0x78, 0x00, 1, remote.Read, false},
+	} {
+		got := p.Allowed(tc.addr, tc.reg, tc.n, tc.a)
+		if got != tc.want {
+			t.Errorf("allowed(0x%02x, %d, %d, %v): got=%v, want=%v", tc.addr, tc.reg, tc.n, tc.a, got, tc.want)
+		}
+	}
+}
+
+func TestPolicyEmpty(t *testing.T) {
+	p := remote.Policy{Rules: []remote.Rule{
+		{Addrs: remote.Bytes{}, Access: remote.ReadWrite},
+		{Addrs: remote.Bytes{0x76}, Regs: remote.Bytes{}, Access: remote.ReadWrite},
+	}}
+	raw, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("could not encode policy: %v", err)
+	}
+	var got remote.Policy
+	err = json.Unmarshal(raw, &got)
+	if err != nil {
+		t.Fatalf("could not decode policy: %v", err)
+	}
+	for i, r := range got.Rules {
+		if r.Addrs == nil || (i == 1 && r.Regs == nil) {
+			t.Fatalf("rule #%d: empty list decoded as nil: %s", i, raw)
+		}
+	}
+	for _, addr := range []uint8{0x76, 0x77} {
+		if got.Allowed(addr, 0x00, 1, remote.Read) || got.Allowed(addr, -1, 0, remote.Read) {
+			t.Fatalf("reloaded policy grants access to 0x%02x: %s", addr, raw)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	bus := smbustest.New()
+	bus.Attach(0x76, &smbustest.Device{})
+	bus.Attach(0x77, &smbustest.Device{})
+
+	audit := new(bytes.Buffer)
+	srv := remote.NewServer()
+	srv.AuditLogger = slog.New(slog.NewTextHandler(audit, nil))
+	srv.Tokens = map[string]string{
+		"s3cr3t": "alice",
+		"t0k3n":  "bob",
+	}
+	srv.Policies = map[string]*remote.Policy{
+		"alice": {Rules: []remote.Rule{{Access: remote.ReadWrite}}},
+		"bob": {Rules: []remote.Rule{
+			{Addrs: []uint8{0x76}, Regs: []uint8{0x10, 0x11}, Access: remote.Read},
+			{Addrs: []uint8{0x76}, Regs: []uint8{0x20}, Access: remote.Write},
+		}},
+	}
+	srv.Export("sim", bus)
+	addr := newServer(t, srv)
+
+	_, err := remote.Dial("tcp", addr, "sim")
+	if !errors.Is(err, remote.ErrDenied) {
+		t.Fatalf("anonymous client: invalid error: got=%v, want=%v", err, remote.ErrDenied)
+	}
+	_, err = remote.Dial("tcp", addr, "sim", remote.Token("guess"))
+	if !errors.Is(err, remote.ErrDenied) {
+		t.Fatalf("invalid token: invalid error: got=%v, want=%v", err, remote.ErrDenied)
+	}
+	if got := strings.Count(audit.String(), "op=hello"); got != 2 {
+		t.Fatalf("invalid audit log: %q", audit)
+	}
+	audit.Reset()
+
+	alice, err := remote.Dial("tcp", addr, "sim", remote.Token("s3cr3t"))
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer alice.Close()
+	bob, err := remote.Dial("tcp", addr, "sim", remote.Token("t0k3n"))
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer bob.Close()
+
+	err = alice.WriteBlockData(0x76, 0x10, []byte{1, 2})
+	if err != nil {
+		t.Fatalf("write-block-data error: %v", err)
+	}
+	err = alice.WriteReg(0x77, 0x00, 3)
+	if err != nil {
+		t.Fatalf("write-reg error: %v", err)
+	}
+	if audit.Len() != 0 {
+		t.Fatalf("unexpected audit log: %s", audit)
+	}
+
+	v, err := bob.ReadWord(0x76, 0x10)
+	if err != nil {
+		t.Fatalf("read-word error: %v", err)
+	}
+	if v != 0x0201 {
+		t.Fatalf("invalid word: got=0x%04x, want=0x0201", v)
+	}
+	err = bob.WriteReg(0x76, 0x20, 4)
+	if err != nil {
+		t.Fatalf("write-reg error: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		f    func() error
+		log  string
+	}{
+		{
+			name: "write read-only register",
+			f:    func() error { return bob.WriteReg(0x76, 0x10, 0) },
+			log:  "op=write-reg addr=0x76 bus=sim reg=0x10 n=1",
+		},
+		{
+			name: "read past granted registers",
+			f:    func() error { return bob.ReadBlockData(0x76, 0x11, make([]byte, 2)) },
+			log:  "op=read-block-data addr=0x76 bus=sim reg=0x11 n=2",
+		},
+		{
+			name: "read other device",
+			f: func() error {
+				_, err := bob.ReadReg(0x77, 0x00)
+				return err
+			},
+			log: "op=read-reg addr=0x77 bus=sim reg=0x00 n=1",
+		},
+		{
+			name: "select other device",
+			f:    func() error { return bob.SetAddr(0x77) },
+			log:  "op=set-addr addr=0x77 bus=sim",
+		},
+		{
+			name: "raw read",
+			f: func() error {
+				err := bob.SetAddr(0x76)
+				if err != nil {
+					return err
+				}
+				_, err = bob.Read(make([]byte, 1))
+				return err
+			},
+			log: "op=read addr=0x76 bus=sim",
+		},
+	} {
+		audit.Reset()
+		err := tc.f()
+		if !errors.Is(err, remote.ErrDenied) {
+			t.Fatalf("%s: invalid error: got=%v, want=%v", tc.name, err, remote.ErrDenied)
+		}
+		if log := audit.String(); !strings.Contains(log, `msg="access denied"`) ||
+			!strings.Contains(log, "id=bob") ||
+			!strings.Contains(log, tc.log) {
+			t.Fatalf("%s: invalid audit log: %q", tc.name, log)
+		}
+	}
+
+	if got := bus.Device(0x76).Regs[0x10]; got != 1 {
+		t.Fatalf("denied write reached the device: got=%d, want=1", got)
+	}
+	if got := bus.Device(0x76).Regs[0x20]; got != 4 {
+		t.Fatalf("invalid register: got=%d, want=4", got)
+	}
+}
+
+func TestPolicyAuditDefault(t *testing.T) {
+	audit := new(bytes.Buffer)
+	def := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(audit, nil)))
+	t.Cleanup(func() { slog.SetDefault(def) })
+
+	bus := smbustest.New()
+	bus.Attach(0x76, &smbustest.Device{})
+
+	srv := remote.NewServer() // neither Logger nor AuditLogger
+	srv.Policies = map[string]*remote.Policy{
+		"": {Rules: []remote.Rule{{Access: remote.Read}}},
+	}
+	srv.Export("sim", bus)
+	addr := newServer(t, srv)
+
+	c, err := remote.Dial("tcp", addr, "sim")
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer c.Close()
+
+	err = c.WriteReg(0x76, 0x00, 1)
+	if !errors.Is(err, remote.ErrDenied) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, remote.ErrDenied)
+	}
+	if log := audit.String(); !strings.Contains(log, `msg="access denied"`) ||
+		!strings.Contains(log, "op=write-reg addr=0x76") {
+		t.Fatalf("denied operation not audited: %q", log)
+	}
+}
+
+func TestPolicyTLS(t *testing.T) {
+	ca := newCert(t, "ca", nil)
+	srvCert := newCert(t, "127.0.0.1", &ca)
+	cliCert := newCert(t, "logger", &ca)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	bus := smbustest.New()
+	bus.Attach(0x76, &smbustest.Device{})
+
+	srv := remote.NewServer()
+	srv.Tokens = map[string]string{"s3cr3t": "admin"}
+	srv.Policies = map[string]*remote.Policy{
+		"logger": {Rules: []remote.Rule{{Access: remote.Read}}},
+		"admin":  {Rules: []remote.Rule{{Access: remote.ReadWrite}}},
+	}
+	srv.Export("sim", bus)
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{srvCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+	})
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	defer l.Close()
+	go srv.Serve(l)
+
+	_, err = remote.Dial("tcp", l.Addr().String(), "sim", remote.TLS(&tls.Config{RootCAs: pool}))
+	if !errors.Is(err, remote.ErrDenied) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, remote.ErrDenied)
+	}
+
+	c, err := remote.Dial("tcp", l.Addr().String(), "sim",
+		remote.TLS(&tls.Config{RootCAs: pool, Certificates: []tls.Certificate{cliCert}}),
+		remote.Token("s3cr3t"), // ignored, in favour of the certificate
+	)
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer c.Close()
+
+	_, err = c.ReadReg(0x76, 0x00)
+	if err != nil {
+		t.Fatalf("read-reg error: %v", err)
+	}
+	err = c.WriteReg(0x76, 0x00, 1)
+	if !errors.Is(err, remote.ErrDenied) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, remote.ErrDenied)
+	}
+	if !strings.Contains(err.Error(), `"logger"`) {
+		t.Fatalf("invalid error: %v", err)
+	}
+}
+
+// newCert returns a certificate for name, signed by parent,
+// or self-signed when parent is nil.
+func newCert(t *testing.T, name string, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if ip := net.ParseIP(name); ip != nil {
+		tmpl.IPAddresses = []net.IP{ip}
+	}
+	var (
+		signer        = tmpl
+		signerKey any = key
+	)
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer = parent.Leaf
+		signerKey = parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("could not create certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("could not parse certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
@@ -0,0 +1,485 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package remote
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-daq/smbus"
+)
+
+// DefaultLockTimeout is the default maximum duration a client may hold the
+// lock of a bus.
+const DefaultLockTimeout = 10 * time.Second
+
+// Server exports buses over the network.
+type Server struct {
+	// Logger, if not nil, receives connection and protocol errors.
+	Logger *slog.Logger
+
+	// AuditLogger receives the denied operations.
+	// AuditLogger defaults to Logger, or to slog.Default() if Logger is
+	// nil as well: denied operations are always audited.
+	AuditLogger *slog.Logger
+
+	// Policies, if not nil, maps client identities to their access policy.
+	// A nil Policies grants full access to all clients.
+	Policies map[string]*Policy
+
+	// Tokens maps authentication tokens to client identities.
+	Tokens map[string]string
+
+	// LockTimeout bounds the duration a client may hold the lock of a
+	// bus. When it expires, the server releases the lock and fails the
+	// requests of the client until it unlocks the bus.
+	// A zero LockTimeout defaults to DefaultLockTimeout.
+	LockTimeout time.Duration
+
+	mu    sync.RWMutex
+	buses map[string]*export
+}
+
+// export is a bus exported by a server.
+type export struct {
+	name string
+	bus  smbus.Bus
+	mu   sync.Mutex // serializes the requests of all connections
+}
+
+// NewServer returns a server exporting no bus.
+func NewServer() *Server {
+	return &Server{buses: make(map[string]*export)}
+}
+
+// Export exports the bus b under the provided name.
+// Export replaces any bus previously exported under that name.
+//
+// Requests of clients are performed with smbus.WithLock, and the locked
+// sections of clients hold the lock of b: when b implements smbus.Locker,
+// local users of b sharing it with the server should use smbus.WithLock
+// for their own multi-step sequences.
+func (srv *Server) Export(name string, b smbus.Bus) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.buses[name] = &export{name: name, bus: b}
+}
+
+func (srv *Server) lookup(name string) (*export, bool) {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+	exp, ok := srv.buses[name]
+	return exp, ok
+}
+
+// Serve accepts connections on l and serves each of them in a new goroutine.
+// Serve returns when l fails to accept a connection, e.g. when l is closed.
+func (srv *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go srv.ServeConn(conn)
+	}
+}
+
+// ServeConn serves the requests of a single connection, until the client
+// closes it or sends an invalid request. ServeConn closes conn.
+func (srv *Server) ServeConn(conn net.Conn) {
+	defer conn.Close()
+
+	sess := &session{srv: srv, conn: conn}
+	defer func() {
+		sess.mu.Lock()
+		defer sess.mu.Unlock()
+		sess.unlock()
+	}()
+
+	if tc, ok := conn.(*tls.Conn); ok {
+		err := tc.Handshake()
+		if err != nil {
+			sess.logf("TLS handshake failed: %v", err)
+			return
+		}
+		if st := tc.ConnectionState(); len(st.VerifiedChains) > 0 {
+			sess.id = st.PeerCertificates[0].Subject.CommonName
+			sess.tls = true
+		}
+	}
+
+	r := bufio.NewReader(conn)
+	for {
+		req, err := readFrame(r)
+		if err != nil {
+			sess.logf("could not read request: %v", err)
+			return
+		}
+		resp, done := sess.handle(req)
+		err = writeFrame(conn, resp)
+		if err != nil {
+			sess.logf("could not write response: %v", err)
+			return
+		}
+		if done {
+			return
+		}
+	}
+}
+
+// session is the state of a client connection.
+type session struct {
+	srv    *Server
+	conn   net.Conn
+	id     string  // identity of the client
+	tls    bool    // whether the identity comes from a TLS certificate
+	policy *Policy // access policy of the client, or nil for full access
+	exp    *export
+
+	mu      sync.Mutex // guards the lock state, against the lock timer
+	locked  bool       // whether the session holds the lock of the bus
+	expired bool       // whether the lock was released by the lock timer
+	gen     int        // generation of the lock, identifying its timer
+	timer   *time.Timer
+
+	held    smbus.Bus     // locked view of the exported bus, while locked
+	release chan struct{} // closed to release the lock of the exported bus
+	done    chan struct{} // closed once the lock of the exported bus is released
+}
+
+func (sess *session) logf(format string, args ...any) {
+	if sess.srv.Logger == nil {
+		return
+	}
+	sess.srv.Logger.Warn(fmt.Sprintf(format, args...), "remote", sess.conn.RemoteAddr().String())
+}
+
+// audit logs the denied operation op.
+func (sess *session) audit(op byte, addr uint8, reg, n int) {
+	l := sess.srv.AuditLogger
+	if l == nil {
+		l = sess.srv.Logger
+	}
+	if l == nil {
+		l = slog.Default()
+	}
+	attrs := []any{
+		"remote", sess.conn.RemoteAddr().String(),
+		"id", sess.id,
+		"op", opNames[op],
+	}
+	if op != opHello && op != opLock {
+		attrs = append(attrs, "addr", smbus.Addr7(addr).String())
+	}
+	if sess.exp != nil {
+		attrs = append(attrs, "bus", sess.exp.name)
+	}
+	if reg >= 0 {
+		attrs = append(attrs, "reg", fmt.Sprintf("0x%02x", reg), "n", n)
+	}
+	l.Warn("access denied", attrs...)
+}
+
+// authorize returns an ErrDenied error if the policy of the client does
+// not grant the access rights a on the n registers starting at reg of the
+// device at address addr. A negative reg designates a raw read or write.
+func (sess *session) authorize(op byte, addr uint8, reg, n int, a Access) error {
+	if sess.policy == nil || sess.policy.Allowed(addr, reg, n, a) {
+		return nil
+	}
+	sess.audit(op, addr, reg, n)
+	return fmt.Errorf("%w: %s %s access to %v", ErrDenied, sess.ident(), a, smbus.Addr7(addr))
+}
+
+func (sess *session) ident() string {
+	if sess.id == "" {
+		return "anonymous"
+	}
+	return fmt.Sprintf("%q", sess.id)
+}
+
+// hello authenticates the client and selects the bus named name.
+func (sess *session) hello(name, token string) error {
+	if !sess.tls && token != "" {
+		id, ok := sess.srv.Tokens[token]
+		if !ok {
+			sess.audit(opHello, 0, -1, 0)
+			return fmt.Errorf("%w: invalid token", ErrDenied)
+		}
+		sess.id = id
+	}
+	if sess.srv.Policies != nil {
+		policy, ok := sess.srv.Policies[sess.id]
+		if !ok {
+			sess.audit(opHello, 0, -1, 0)
+			return fmt.Errorf("%w: no policy for %s", ErrDenied, sess.ident())
+		}
+		sess.policy = policy
+	}
+
+	exp, ok := sess.srv.lookup(name)
+	if !ok {
+		return fmt.Errorf("remote: unknown bus %q", name)
+	}
+	sess.exp = exp
+	return nil
+}
+
+// lock gives the session exclusive access to the exported bus, from other
+// connections and from local users of the bus, until unlock is called or
+// the lock timeout of the server expires.
+func (sess *session) lock() error {
+	sess.exp.mu.Lock()
+
+	var (
+		held    = make(chan smbus.Bus)
+		release = make(chan struct{})
+		done    = make(chan struct{})
+		err     error
+	)
+	go func() {
+		defer close(done)
+		err = smbus.WithLock(sess.exp.bus, func(b smbus.Bus) error {
+			held <- b
+			<-release
+			return nil
+		})
+	}()
+	select {
+	case sess.held = <-held:
+	case <-done:
+		sess.exp.mu.Unlock()
+		return err
+	}
+	sess.release = release
+	sess.done = done
+	sess.locked = true
+
+	d := sess.srv.LockTimeout
+	if d <= 0 {
+		d = DefaultLockTimeout
+	}
+	sess.gen++
+	gen := sess.gen
+	sess.timer = time.AfterFunc(d, func() {
+		sess.mu.Lock()
+		defer sess.mu.Unlock()
+		if !sess.locked || sess.gen != gen {
+			return
+		}
+		sess.logf("lock of bus %q held for more than %v: releasing it", sess.exp.name, d)
+		sess.unlock()
+		sess.expired = true
+	})
+	return nil
+}
+
+// unlock releases the lock of the bus, if held.
+func (sess *session) unlock() {
+	if !sess.locked {
+		return
+	}
+	sess.timer.Stop()
+	close(sess.release)
+	<-sess.done
+	sess.held = nil
+	sess.locked = false
+	sess.exp.mu.Unlock()
+}
+
+// do calls f with the exported bus, with exclusive access to it.
+func (sess *session) do(f func(b smbus.Bus) error) error {
+	if sess.locked {
+		return f(sess.held)
+	}
+	sess.exp.mu.Lock()
+	defer sess.exp.mu.Unlock()
+	return smbus.WithLock(sess.exp.bus, f)
+}
+
+var (
+	errNoHello  = errors.New("remote: hello required")
+	errBadReq   = errors.New("remote: malformed request")
+	errUnknown  = errors.New("remote: unknown operation")
+	errVersion  = errors.New("remote: unsupported protocol version")
+	errLocked   = errors.New("remote: bus already locked by this connection")
+	errUnlocked = errors.New("remote: bus not locked by this connection")
+	errExpired  = errors.New("remote: lock timeout expired")
+)
+
+// handle executes the request req and returns the response body, and
+// whether the connection should be closed.
+func (sess *session) handle(req []byte) ([]byte, bool) {
+	if len(req) == 0 {
+		return encodeErr(errBadReq), true
+	}
+	op, args := req[0], req[1:]
+
+	if len(args) < reqArgs[op] {
+		return encodeErr(errBadReq), true
+	}
+
+	if sess.exp == nil {
+		if op != opHello {
+			return encodeErr(errNoHello), true
+		}
+		if args[0] != version {
+			return encodeErr(errVersion), true
+		}
+		n := int(args[1])
+		if len(args) < 2+n {
+			return encodeErr(errBadReq), true
+		}
+		err := sess.hello(string(args[2:2+n]), string(args[2+n:]))
+		if err != nil {
+			return encodeErr(err), true
+		}
+		return []byte{statusOK}, false
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if sess.expired && op != opUnlock && op != opClose {
+		// the client still assumes it holds the lock: its locked
+		// section must fail.
+		return encodeErr(errExpired), false
+	}
+
+	var (
+		resp = []byte{statusOK}
+		err  error
+	)
+	switch op {
+	case opSetAddr:
+		if sess.policy != nil && !sess.policy.addressable(args[0]) {
+			sess.audit(op, args[0], -1, 0)
+			err = fmt.Errorf("%w: %s access to %v", ErrDenied, sess.ident(), smbus.Addr7(args[0]))
+			break
+		}
+		err = sess.do(func(b smbus.Bus) error {
+			return b.SetAddr(args[0])
+		})
+	case opRead:
+		if err = sess.authorize(op, args[0], -1, 0, Read); err != nil {
+			break
+		}
+		buf := make([]byte, binary.BigEndian.Uint16(args[1:]))
+		err = sess.do(func(b smbus.Bus) error {
+			err := b.SetAddr(args[0])
+			if err != nil {
+				return err
+			}
+			n, err := b.Read(buf)
+			buf = buf[:n]
+			return err
+		})
+		resp = append(resp, buf...)
+	case opWrite:
+		if err = sess.authorize(op, args[0], -1, 0, Write); err != nil {
+			break
+		}
+		var n int
+		err = sess.do(func(b smbus.Bus) error {
+			err := b.SetAddr(args[0])
+			if err != nil {
+				return err
+			}
+			n, err = b.Write(args[1:])
+			return err
+		})
+		resp = binary.BigEndian.AppendUint16(resp, uint16(n))
+	case opReadReg:
+		if err = sess.authorize(op, args[0], int(args[1]), 1, Read); err != nil {
+			break
+		}
+		var v uint8
+		err = sess.do(func(b smbus.Bus) error {
+			var err error
+			v, err = b.ReadReg(args[0], args[1])
+			return err
+		})
+		resp = append(resp, v)
+	case opWriteReg:
+		if err = sess.authorize(op, args[0], int(args[1]), 1, Write); err != nil {
+			break
+		}
+		err = sess.do(func(b smbus.Bus) error {
+			return b.WriteReg(args[0], args[1], args[2])
+		})
+	case opReadWord:
+		if err = sess.authorize(op, args[0], int(args[1]), 2, Read); err != nil {
+			break
+		}
+		var v uint16
+		err = sess.do(func(b smbus.Bus) error {
+			var err error
+			v, err = b.ReadWord(args[0], args[1])
+			return err
+		})
+		resp = append(resp, uint8(v), uint8(v>>8))
+	case opWriteWord:
+		if err = sess.authorize(op, args[0], int(args[1]), 2, Write); err != nil {
+			break
+		}
+		err = sess.do(func(b smbus.Bus) error {
+			return b.WriteWord(args[0], args[1], uint16(args[2])|uint16(args[3])<<8)
+		})
+	case opReadBlock:
+		if err = sess.authorize(op, args[0], int(args[1]), int(args[2]), Read); err != nil {
+			break
+		}
+		buf := make([]byte, args[2])
+		err = sess.do(func(b smbus.Bus) error {
+			return b.ReadBlockData(args[0], args[1], buf)
+		})
+		resp = append(resp, buf...)
+	case opWriteBlock:
+		if err = sess.authorize(op, args[0], int(args[1]), len(args[2:]), Write); err != nil {
+			break
+		}
+		err = sess.do(func(b smbus.Bus) error {
+			return b.WriteBlockData(args[0], args[1], args[2:])
+		})
+	case opLock:
+		if sess.locked {
+			err = errLocked
+			break
+		}
+		if sess.policy != nil && !sess.policy.lockable() {
+			sess.audit(op, 0, -1, 0)
+			err = fmt.Errorf("%w: %s lock access to bus %q", ErrDenied, sess.ident(), sess.exp.name)
+			break
+		}
+		err = sess.lock()
+	case opUnlock:
+		if sess.expired {
+			sess.expired = false
+			err = errExpired
+			break
+		}
+		if !sess.locked {
+			err = errUnlocked
+			break
+		}
+		sess.unlock()
+	case opClose:
+		return resp, true
+	default:
+		return encodeErr(errUnknown), true
+	}
+
+	if err != nil {
+		return encodeErr(err), false
+	}
+	return resp, false
+}
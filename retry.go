@@ -0,0 +1,132 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/go-daq/smbus/internal/xtime"
+)
+
+// DefaultRetryErrnos is the list of errno values considered transient
+// by a RetryPolicy with no explicit Errnos.
+var DefaultRetryErrnos = []syscall.Errno{
+	syscall.EAGAIN,
+	syscall.EIO,
+	syscall.ETIMEDOUT,
+	syscall.EREMOTEIO,
+	syscall.EBADMSG,
+}
+
+// RetryPolicy describes how failed SMBus transactions are retried in userspace.
+// The zero value does not retry.
+type RetryPolicy struct {
+	Count   int             // maximum number of retries
+	Backoff time.Duration   // delay before the first retry, doubled after each retry
+	Errnos  []syscall.Errno // retryable errno values (DefaultRetryErrnos if nil)
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	errnos := p.Errnos
+	if errnos == nil {
+		errnos = DefaultRetryErrnos
+	}
+	for _, v := range errnos {
+		if v == errno {
+			return true
+		}
+	}
+	return false
+}
+
+// do runs f, retrying it according to the policy.
+// do gives up as soon as ctx is done.
+func (p RetryPolicy) do(ctx context.Context, f func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := f()
+	delay := p.Backoff
+	for i := 0; i < p.Count && err != nil && p.retryable(err); i++ {
+		if delay > 0 {
+			if err := xtime.Sleep(ctx, delay); err != nil {
+				return err
+			}
+			delay *= 2
+		}
+		err = f()
+	}
+	return err
+}
+
+// Timeout configures the timeout of the i2c adapter, as used by the kernel.
+// The kernel timeout has a resolution of 10ms.
+// Opening the connection fails if d is negative.
+func Timeout(d time.Duration) func(cfg *config) {
+	return func(cfg *config) {
+		cfg.Timeout = d
+	}
+}
+
+// Retries configures the number of times the kernel retries a transaction
+// when the device does not acknowledge it.
+// Opening the connection fails if n is negative.
+func Retries(n int) func(cfg *config) {
+	return func(cfg *config) {
+		cfg.Retries = &n
+	}
+}
+
+// Retry configures the userspace retry policy, applied to every SMBus operation.
+func Retry(p RetryPolicy) func(cfg *config) {
+	return func(cfg *config) {
+		cfg.Retry = p
+	}
+}
+
+// SetTimeout sets the timeout of the i2c adapter, as used by the kernel.
+// The kernel timeout has a resolution of 10ms.
+// SetTimeout returns an error if d is negative.
+func (c *Conn) SetTimeout(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("smbus: invalid timeout %v", d)
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	v := (d + 10*time.Millisecond - 1) / (10 * time.Millisecond)
+	return c.wrap("set-timeout", c.slave, -1, c.f.ioctl(i2cTimeout, uintptr(v)))
+}
+
+// SetRetries sets the number of times the kernel retries a transaction
+// when the device does not acknowledge it.
+// SetRetries returns an error if n is negative.
+func (c *Conn) SetRetries(n int) error {
+	if n < 0 {
+		return fmt.Errorf("smbus: invalid number of retries %d", n)
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	return c.wrap("set-retries", c.slave, -1, c.f.ioctl(i2cRetries, uintptr(n)))
+}
+
+// SetRetryPolicy sets the userspace retry policy, applied to every SMBus operation.
+func (c *Conn) SetRetryPolicy(p RetryPolicy) {
+	c.lock()
+	defer c.unlock()
+
+	c.retry = p
+}
@@ -0,0 +1,180 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+// ScanMode describes how addresses are probed during a bus scan.
+type ScanMode int
+
+// Scan modes.
+const (
+	// ScanAuto probes addresses with a quick write, except for the
+	// 0x30-0x37 and 0x50-0x5f ranges (where EEPROMs usually sit) that
+	// are probed with a receive byte, as i2cdetect does.
+	ScanAuto  ScanMode = iota
+	ScanQuick          // probe addresses with a SMBus quick write
+	ScanRead           // probe addresses with a SMBus receive byte
+)
+
+// AddrStatus describes the status of an address after a bus scan.
+type AddrStatus uint8
+
+// Address statuses.
+const (
+	AddrNotProbed AddrStatus = iota // address was not probed
+	AddrAbsent                      // no device answered
+	AddrPresent                     // a device answered
+	AddrBusy                        // address is in use by a kernel driver
+)
+
+func (s AddrStatus) String() string {
+	switch s {
+	case AddrNotProbed:
+		return "not-probed"
+	case AddrAbsent:
+		return "absent"
+	case AddrPresent:
+		return "present"
+	case AddrBusy:
+		return "busy"
+	}
+	return fmt.Sprintf("AddrStatus(%d)", uint8(s))
+}
+
+// ScanResult holds the status of all the 7-bit addresses of a bus.
+type ScanResult [0x80]AddrStatus
+
+// Present returns the addresses where a device answered.
+func (r *ScanResult) Present() []uint8 {
+	return r.addrs(AddrPresent)
+}
+
+// Busy returns the addresses in use by a kernel driver.
+func (r *ScanResult) Busy() []uint8 {
+	return r.addrs(AddrBusy)
+}
+
+func (r *ScanResult) addrs(s AddrStatus) []uint8 {
+	var addrs []uint8
+	for addr, v := range r {
+		if v == s {
+			addrs = append(addrs, uint8(addr))
+		}
+	}
+	return addrs
+}
+
+// String returns the scan result formatted as a table, like i2cdetect does.
+func (r *ScanResult) String() string {
+	var o strings.Builder
+	o.WriteString("     0  1  2  3  4  5  6  7  8  9  a  b  c  d  e  f\n")
+	for row := 0; row < len(r); row += 16 {
+		fmt.Fprintf(&o, "%02x: ", row)
+		for addr := row; addr < row+16; addr++ {
+			switch r[addr] {
+			case AddrAbsent:
+				o.WriteString("-- ")
+			case AddrPresent:
+				fmt.Fprintf(&o, "%02x ", addr)
+			case AddrBusy:
+				o.WriteString("UU ")
+			default:
+				o.WriteString("   ")
+			}
+		}
+		o.WriteString("\n")
+	}
+	return o.String()
+}
+
+// prober is implemented by buses able to probe device addresses.
+type prober interface {
+	WriteQuick(addr, bit uint8) error
+	ReceiveByte(addr uint8) (uint8, error)
+}
+
+// Scan probes the addresses 0x03 to 0x77 of the bus b, with the ScanAuto mode.
+func Scan(b Bus) (*ScanResult, error) {
+	return ScanRange(b, ScanAuto, 0x03, 0x77)
+}
+
+// ScanRange probes the addresses first to last (inclusive) of the bus b,
+// with the provided mode.
+//
+// The probe method of the ScanAuto mode is adjusted to the functionalities
+// of the adapter, when b reports them.
+// Addresses that do not acknowledge the probe are reported as absent:
+// ScanRange fails on any other probe error.
+// Probing is known to confuse, or even corrupt, some devices.
+func ScanRange(b Bus, mode ScanMode, first, last uint8) (*ScanResult, error) {
+	if first > last || last > 0x7f {
+		return nil, fmt.Errorf("smbus: invalid scan range [0x%02x, 0x%02x]", first, last)
+	}
+
+	var r ScanResult
+	err := WithLock(b, func(b Bus) error {
+		p, ok := b.(prober)
+		if !ok {
+			return fmt.Errorf("%w: scan", ErrUnsupported)
+		}
+
+		quick, read := true, true
+		if f, ok := b.(interface{ Funcs() (Funcs, error) }); ok {
+			if fs, err := f.Funcs(); err == nil {
+				quick = fs.Has(FuncSMBusQuick)
+				read = fs.Has(FuncSMBusReadByte)
+			}
+		}
+
+		switch {
+		case mode == ScanQuick && !quick, mode == ScanRead && !read, !quick && !read:
+			return fmt.Errorf("%w: scan (missing quick write or receive byte)", ErrUnsupported)
+		}
+
+		for addr := int(first); addr <= int(last); addr++ {
+			useRead := false
+			switch mode {
+			case ScanRead:
+				useRead = true
+			case ScanAuto:
+				useRead = (0x30 <= addr && addr <= 0x37) || (0x50 <= addr && addr <= 0x5f)
+				useRead = (useRead && read) || !quick
+			}
+
+			var err error
+			if useRead {
+				_, err = p.ReceiveByte(uint8(addr))
+			} else {
+				err = p.WriteQuick(uint8(addr), i2cSMBusWrite)
+			}
+			switch {
+			case err == nil:
+				r[addr] = AddrPresent
+			case errors.Is(err, syscall.EBUSY):
+				r[addr] = AddrBusy
+			case errors.Is(err, ErrNACK), classify(err) == ErrNACK:
+				r[addr] = AddrAbsent
+			default:
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Scan probes the addresses 0x03 to 0x77 of the bus, with the ScanAuto mode.
+func (c *Conn) Scan() (*ScanResult, error) {
+	return Scan(c)
+}
@@ -0,0 +1,87 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus_test
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/go-daq/smbus"
+	"github.com/go-daq/smbus/smbustest"
+)
+
+func TestScan(t *testing.T) {
+	bus := smbustest.New()
+	defer bus.Close()
+
+	for _, addr := range []uint8{0x01, 0x29, 0x50, 0x76} {
+		bus.Attach(addr, &smbustest.Device{})
+	}
+	bus.Attach(0x77, &smbustest.Device{Busy: true})
+
+	r, err := smbus.Scan(bus)
+	if err != nil {
+		t.Fatalf("scan error: %v", err)
+	}
+
+	if got, want := r.Present(), []uint8{0x29, 0x50, 0x76}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid present addresses: got=%x, want=%x", got, want)
+	}
+	if got, want := r.Busy(), []uint8{0x77}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid busy addresses: got=%x, want=%x", got, want)
+	}
+	if got, want := r[0x01], smbus.AddrNotProbed; got != want {
+		t.Fatalf("invalid status for 0x01: got=%v, want=%v", got, want)
+	}
+	if got, want := r[0x42], smbus.AddrAbsent; got != want {
+		t.Fatalf("invalid status for 0x42: got=%v, want=%v", got, want)
+	}
+
+	want := `     0  1  2  3  4  5  6  7  8  9  a  b  c  d  e  f
+00:          -- -- -- -- -- -- -- -- -- -- -- -- -- 
+10: -- -- -- -- -- -- -- -- -- -- -- -- -- -- -- -- 
+20: -- -- -- -- -- -- -- -- -- 29 -- -- -- -- -- -- 
+30: -- -- -- -- -- -- -- -- -- -- -- -- -- -- -- -- 
+40: -- -- -- -- -- -- -- -- -- -- -- -- -- -- -- -- 
+50: 50 -- -- -- -- -- -- -- -- -- -- -- -- -- -- -- 
+60: -- -- -- -- -- -- -- -- -- -- -- -- -- -- -- -- 
+70: -- -- -- -- -- -- 76 UU                         
+`
+	if got := r.String(); got != want {
+		t.Fatalf("invalid table:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	bus.Close()
+	_, err = smbus.Scan(bus)
+	if !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("invalid error scanning a closed bus: got=%v, want=%v", err, os.ErrClosed)
+	}
+}
+
+func TestScanConn(t *testing.T) {
+	c, bus := newSimConn(0x29, 0x50)
+	defer c.Close()
+	bus.Attach(0x76, &smbustest.Device{Busy: true})
+
+	r, err := smbus.ScanRange(c, smbus.ScanQuick, 0x08, 0x77)
+	if err != nil {
+		t.Fatalf("scan error: %v", err)
+	}
+	if got, want := r.Present(), []uint8{0x29, 0x50}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid present addresses: got=%x, want=%x", got, want)
+	}
+	if got, want := r.Busy(), []uint8{0x76}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid busy addresses: got=%x, want=%x", got, want)
+	}
+
+	c, _ = newSimConnFuncs(smbus.FuncI2C)
+	defer c.Close()
+	_, err = c.Scan()
+	if err == nil {
+		t.Fatalf("expected an error scanning without quick write nor receive byte")
+	}
+}
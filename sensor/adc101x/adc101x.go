@@ -9,6 +9,7 @@
 package adc101x
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 
@@ -21,7 +22,7 @@ const (
 
 // Device is a handle to an ADC101x device.
 type Device struct {
-	conn *smbus.Conn
+	conn smbus.Bus
 	addr uint8
 	bits uint8
 
@@ -30,7 +31,7 @@ type Device struct {
 }
 
 // Open opens a connection to an ADC101x device.
-func Open(conn *smbus.Conn, addr uint8, frange int, vdd float64) (*Device, error) {
+func Open(conn smbus.Bus, addr uint8, frange int, vdd float64) (*Device, error) {
 	dev := &Device{
 		conn:   conn,
 		addr:   addr,
@@ -41,7 +42,7 @@ func Open(conn *smbus.Conn, addr uint8, frange int, vdd float64) (*Device, error
 
 	err := dev.conn.SetAddr(dev.addr)
 	if err != nil {
-		return nil, fmt.Errorf("adc101x: error in set-addr: %v", err)
+		return nil, fmt.Errorf("adc101x: error in set-addr: %w", err)
 	}
 
 	const (
@@ -50,17 +51,23 @@ func Open(conn *smbus.Conn, addr uint8, frange int, vdd float64) (*Device, error
 	)
 	err = dev.conn.WriteReg(dev.addr, configRegister, autoConvMode)
 	if err != nil {
-		return nil, fmt.Errorf("adc101x: error in write-reg: %v", err)
+		return nil, fmt.Errorf("adc101x: error in write-reg: %w", err)
 	}
 
 	return dev, nil
 }
 
 func (dev *Device) ADC() (int, error) {
+	return dev.ADCContext(context.Background())
+}
+
+// ADCContext returns the 10-bit value of the last conversion.
+// ADCContext gives up as soon as ctx is done.
+func (dev *Device) ADCContext(ctx context.Context) (int, error) {
 	var buf [2]byte
-	err := dev.conn.ReadBlockData(dev.addr, 0x000, buf[:])
+	err := smbus.WithContext(ctx, dev.conn).ReadBlockData(dev.addr, 0x000, buf[:])
 	if err != nil {
-		return 0, fmt.Errorf("adc101x: error in read-block-data: %v", err)
+		return 0, fmt.Errorf("adc101x: error in read-block-data: %w", err)
 	}
 
 	raw := binary.BigEndian.Uint16(buf[:])
@@ -71,7 +78,13 @@ func (dev *Device) ADC() (int, error) {
 }
 
 func (dev *Device) Voltage() (float64, error) {
-	adc, err := dev.ADC()
+	return dev.VoltageContext(context.Background())
+}
+
+// VoltageContext returns the voltage of the last conversion.
+// VoltageContext gives up as soon as ctx is done.
+func (dev *Device) VoltageContext(ctx context.Context) (float64, error) {
+	adc, err := dev.ADCContext(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -0,0 +1,47 @@
+// Copyright 2018 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package adc101x_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-daq/smbus/sensor/adc101x"
+	"github.com/go-daq/smbus/smbustest"
+)
+
+func TestADC(t *testing.T) {
+	bus := smbustest.New()
+	defer bus.Close()
+
+	dev := &smbustest.Device{}
+	dev.MustSet(0x00, 0x0a, 0xbc)
+	bus.Attach(adc101x.DefaultI2CAddr, dev)
+
+	adc, err := adc101x.Open(bus, adc101x.DefaultI2CAddr, 1024, 3.3)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+
+	if got, want := dev.Regs[0x02], uint8(0x20); got != want {
+		t.Fatalf("invalid config register: got=0x%x, want=0x%x", got, want)
+	}
+
+	v, err := adc.ADC()
+	if err != nil {
+		t.Fatalf("adc error: %v", err)
+	}
+	if want := 0xabc >> 2; v != want {
+		t.Fatalf("invalid adc value: got=%d, want=%d", v, want)
+	}
+
+	volt, err := adc.Voltage()
+	if err != nil {
+		t.Fatalf("voltage error: %v", err)
+	}
+	if want := 3.3 * float64(0xabc>>2) / 1024; math.Abs(volt-want) > 1e-9 {
+		t.Fatalf("invalid voltage: got=%v, want=%v", volt, want)
+	}
+}
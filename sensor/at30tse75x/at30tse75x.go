@@ -6,6 +6,7 @@
 package at30tse75x
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/go-daq/smbus"
@@ -88,7 +89,7 @@ func EEPROM(size int) func(cfg *config) {
 
 // Device is a handle to an AT30TSE75x device.
 type Device struct {
-	conn  *smbus.Conn
+	conn  smbus.Bus
 	addr  uint8
 	esize int // EEPROM size in bytes
 	eaddr uint8
@@ -96,7 +97,7 @@ type Device struct {
 }
 
 // Open opens a connection to an AT30TSE75x device with the given configuration.
-func Open(conn *smbus.Conn, opts ...func(cfg *config)) (*Device, error) {
+func Open(conn smbus.Bus, opts ...func(cfg *config)) (*Device, error) {
 	cfg := config{
 		I2CAddr: DefaultI2CAddr,
 		DevAddr: 0,
@@ -129,7 +130,13 @@ func Open(conn *smbus.Conn, opts ...func(cfg *config)) (*Device, error) {
 
 // T returns the temperature as measured by the sensor, in degrees Celsius.
 func (dev *Device) T() (float64, error) {
-	reg, err := dev.regTemp()
+	return dev.TContext(context.Background())
+}
+
+// TContext returns the temperature as measured by the sensor, in degrees Celsius.
+// TContext gives up as soon as ctx is done.
+func (dev *Device) TContext(ctx context.Context) (float64, error) {
+	reg, err := dev.regTemp(smbus.WithContext(ctx, dev.conn))
 	if err != nil {
 		return 0, err
 	}
@@ -138,12 +145,11 @@ func (dev *Device) T() (float64, error) {
 	return v, nil
 }
 
-func (dev *Device) regTemp() (uint16, error) {
-	reg, err := dev.conn.ReadWord(dev.addr, regTemp)
+func (dev *Device) regTemp(conn smbus.Bus) (uint16, error) {
+	reg, err := smbus.ReadUint16BE(conn, dev.addr, regTemp)
 	if err != nil {
-		return 0, fmt.Errorf("at30tse75x: failed to retrieve temperature register: %v", err)
+		return 0, fmt.Errorf("at30tse75x: failed to retrieve temperature register: %w", err)
 	}
-	reg = ((0x00ff & reg) << 8) + ((0xff00 & reg) >> 8)
 	return reg, nil
 }
 
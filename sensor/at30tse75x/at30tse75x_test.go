@@ -0,0 +1,34 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package at30tse75x_test
+
+import (
+	"testing"
+
+	"github.com/go-daq/smbus/sensor/at30tse75x"
+	"github.com/go-daq/smbus/smbustest"
+)
+
+func TestT(t *testing.T) {
+	bus := smbustest.New()
+	defer bus.Close()
+
+	dev := &smbustest.Device{}
+	dev.MustSet(0x00, 0x19, 0x80) // 25.5C, MSB first
+	bus.Attach(at30tse75x.DefaultI2CAddr, dev)
+
+	sensor, err := at30tse75x.Open(bus)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+
+	v, err := sensor.T()
+	if err != nil {
+		t.Fatalf("temperature error: %v", err)
+	}
+	if want := 25.5; v != want {
+		t.Fatalf("invalid temperature: got=%v, want=%v", v, want)
+	}
+}
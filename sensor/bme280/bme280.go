@@ -7,10 +7,13 @@ package bme280
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
 	"time"
 
 	"github.com/go-daq/smbus"
+	"github.com/go-daq/smbus/internal/xtime"
 )
 
 const (
@@ -68,7 +71,7 @@ const (
 
 // Device is a handle to a BME280 device
 type Device struct {
-	conn  *smbus.Conn
+	conn  smbus.Bus
 	addr  uint8
 	mode  OpMode
 	calib struct {
@@ -80,7 +83,7 @@ type Device struct {
 }
 
 // Open opens a connection to a BME280 device at the given address.
-func Open(conn *smbus.Conn, addr uint8, mode OpMode) (*Device, error) {
+func Open(conn smbus.Bus, addr uint8, mode OpMode) (*Device, error) {
 	dev := &Device{
 		conn: conn,
 		addr: addr,
@@ -113,14 +116,14 @@ func (dev *Device) loadCalibration() error {
 	}
 
 	var buf [18]byte
-	err = dev.conn.ReadBlockData(dev.addr, regDigH1, buf[:1])
+	err = dev.readBlock(dev.conn, regDigH1, buf[:1])
 	if err != nil {
 		return err
 	}
 
 	dev.calib.h.H1 = uint8(buf[0])
 
-	err = dev.conn.ReadBlockData(dev.addr, regDigH2, buf[:7])
+	err = dev.readBlock(dev.conn, regDigH2, buf[:7])
 	if err != nil {
 		return err
 	}
@@ -131,7 +134,7 @@ func (dev *Device) loadCalibration() error {
 	dev.calib.h.H5 = int16(buf[4]&0xF0)<<4 | int16(buf[5])
 	dev.calib.h.H6 = int8(buf[6])
 
-	err = dev.conn.ReadBlockData(dev.addr, regDigP1, buf[:18])
+	err = dev.readBlock(dev.conn, regDigP1, buf[:18])
 	if err != nil {
 		return err
 	}
@@ -141,7 +144,7 @@ func (dev *Device) loadCalibration() error {
 		return err
 	}
 
-	err = dev.conn.ReadBlockData(dev.addr, regDigT1, buf[:6])
+	err = dev.readBlock(dev.conn, regDigT1, buf[:6])
 	if err != nil {
 		return err
 	}
@@ -156,7 +159,13 @@ func (dev *Device) loadCalibration() error {
 
 // Sample returns the (compensated) Humidity, Pressure and Temperature data off the device.
 func (dev *Device) Sample() (h, p, t float64, err error) {
-	hh, pp, tt, err := dev.raw()
+	return dev.SampleContext(context.Background())
+}
+
+// SampleContext returns the (compensated) Humidity, Pressure and Temperature data off the device.
+// SampleContext gives up as soon as ctx is done.
+func (dev *Device) SampleContext(ctx context.Context) (h, p, t float64, err error) {
+	hh, pp, tt, err := dev.raw(ctx)
 	if err != nil {
 		return h, p, t, err
 	}
@@ -220,18 +229,20 @@ func (dev *Device) Sample() (h, p, t float64, err error) {
 }
 
 // raw returns the raw HPT data from the device.
-func (dev *Device) raw() (h, p, t int32, err error) {
-	t, err = dev.rawT()
+func (dev *Device) raw(ctx context.Context) (h, p, t int32, err error) {
+	conn := smbus.WithContext(ctx, dev.conn)
+
+	t, err = dev.rawT(ctx, conn)
 	if err != nil {
 		return
 	}
 
-	p, err = dev.rawP()
+	p, err = dev.rawP(conn)
 	if err != nil {
 		return
 	}
 
-	h, err = dev.rawH()
+	h, err = dev.rawH(conn)
 	if err != nil {
 		return
 	}
@@ -239,71 +250,73 @@ func (dev *Device) raw() (h, p, t int32, err error) {
 	return h, p, t, nil
 }
 
-func (dev *Device) rawT() (t int32, err error) {
+func (dev *Device) rawT(ctx context.Context, conn smbus.Bus) (t int32, err error) {
 	/*
 		mode=4 meas=145 sleep=0.1128 msb=127 lsb=47 xlsb=0 raw=520944
 	*/
 
 	meas := uint8(dev.mode)
-	err = dev.conn.WriteReg(dev.addr, regControlHum, meas)
+	err = conn.WriteReg(dev.addr, regControlHum, meas)
 	if err != nil {
 		return
 	}
 
 	ctl := meas<<5 | meas<<2 | 1
-	err = dev.conn.WriteReg(dev.addr, regControl, ctl)
+	err = conn.WriteReg(dev.addr, regControl, ctl)
 	if err != nil {
 		return
 	}
 
 	mode := uint8(dev.mode)
 	sleep := 0.00125 + 3*0.0023*float64(uint64(1)<<mode) + 2*0.000575
-	time.Sleep(time.Duration(sleep*1e6) * time.Microsecond)
-
-	msb, err := dev.conn.ReadReg(dev.addr, regTempData)
+	err = xtime.Sleep(ctx, time.Duration(sleep*1e6)*time.Microsecond)
 	if err != nil {
 		return
 	}
-	lsb, err := dev.conn.ReadReg(dev.addr, regTempData+1)
-	if err != nil {
-		return
-	}
-	xlsb, err := dev.conn.ReadReg(dev.addr, regTempData+2)
+
+	var buf [3]byte
+	err = dev.readBlock(conn, regTempData, buf[:])
 	if err != nil {
 		return
 	}
-	t = (int32(msb)<<16 | int32(lsb)<<8 | int32(xlsb)) >> 4
+	t = (int32(buf[0])<<16 | int32(buf[1])<<8 | int32(buf[2])) >> 4
 	return
 }
 
-func (dev *Device) rawP() (p int32, err error) {
-	msb, err := dev.conn.ReadReg(dev.addr, regPressureData)
-	if err != nil {
-		return
-	}
-	lsb, err := dev.conn.ReadReg(dev.addr, regPressureData+1)
+func (dev *Device) rawP(conn smbus.Bus) (p int32, err error) {
+	var buf [3]byte
+	err = dev.readBlock(conn, regPressureData, buf[:])
 	if err != nil {
 		return
 	}
-	xlsb, err := dev.conn.ReadReg(dev.addr, regPressureData+2)
+	p = (int32(buf[0])<<16 | int32(buf[1])<<8 | int32(buf[2])) >> 4
+	return
+}
+
+func (dev *Device) rawH(conn smbus.Bus) (h int32, err error) {
+	raw, err := smbus.ReadUint16BE(conn, dev.addr, regHumidityData)
 	if err != nil {
 		return
 	}
-	p = (int32(msb)<<16 | int32(lsb)<<8 | int32(xlsb)) >> 4
+	h = int32(raw)
 	return
 }
 
-func (dev *Device) rawH() (h int32, err error) {
-	msb, err := dev.conn.ReadReg(dev.addr, regHumidityData)
-	if err != nil {
-		return
+// readBlock reads the registers starting at reg into buf, with an i2c
+// block read, or one register at a time if the adapter does not support
+// i2c block reads.
+func (dev *Device) readBlock(conn smbus.Bus, reg uint8, buf []byte) error {
+	err := conn.ReadBlockData(dev.addr, reg, buf)
+	if !errors.Is(err, smbus.ErrUnsupported) {
+		return err
 	}
-	lsb, err := dev.conn.ReadReg(dev.addr, regHumidityData+1)
-	if err != nil {
-		return
+	for i := range buf {
+		buf[i], err = conn.ReadReg(dev.addr, reg+uint8(i))
+		if err != nil {
+			return err
+		}
 	}
-	h = int32(msb)<<8 | int32(lsb)
-	return
+	return nil
 }
 
 // regT holds registers values for the temperature
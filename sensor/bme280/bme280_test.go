@@ -0,0 +1,53 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bme280_test
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/go-daq/smbus/sensor/bme280"
+	"github.com/go-daq/smbus/smbustest"
+)
+
+func TestSample(t *testing.T) {
+	bus := smbustest.New()
+	defer bus.Close()
+
+	dev := &smbustest.Device{}
+	// calibration values from the BME280 datasheet example.
+	for i, v := range []int{
+		27504, 26435, -1000, // T1-T3
+		36477, -10685, 3024, 2855, 140, -7, 15500, -14600, 6000, // P1-P9
+	} {
+		binary.LittleEndian.PutUint16(dev.Regs[0x88+2*i:], uint16(v))
+	}
+	dev.MustSet(0xa1, 75)                                       // H1
+	dev.MustSet(0xe1, 0x6a, 0x01, 0x00, 0x13, 0x2d, 0x03, 0x1e) // H2-H6
+	dev.MustSet(0xf7, 0x65, 0x5a, 0xc0)                         // raw pressure: 415148
+	dev.MustSet(0xfa, 0x7e, 0xed, 0x00)                         // raw temperature: 519888
+	dev.MustSet(0xfd, 0x6e, 0x4a)                               // raw humidity
+	bus.Attach(bme280.I2CAddr, dev)
+
+	sensor, err := bme280.Open(bus, bme280.I2CAddr, bme280.OpSample8)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+
+	h, p, temp, err := sensor.Sample()
+	if err != nil {
+		t.Fatalf("sample error: %v", err)
+	}
+	if want := 25.08; math.Abs(temp-want) > 0.01 {
+		t.Fatalf("invalid temperature: got=%v, want=%v", temp, want)
+	}
+	if want := 100653.0; math.Abs(p-want) > 1 {
+		t.Fatalf("invalid pressure: got=%v, want=%v", p, want)
+	}
+	if h < 0 || h > 100 {
+		t.Fatalf("invalid humidity: got=%v", h)
+	}
+}
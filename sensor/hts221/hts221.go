@@ -6,11 +6,12 @@
 package hts221
 
 import (
-	"encoding/binary"
+	"context"
 	"fmt"
 	"math"
 
 	"github.com/go-daq/smbus"
+	"github.com/go-daq/smbus/regmap"
 )
 
 const (
@@ -19,46 +20,51 @@ const (
 
 // Averaged humidity samples configuration
 const (
-	regAVGH4   = 0x00
-	regAVGH8   = 0x01
-	regAVGH16  = 0x02
-	regAVGH32  = 0x03 // default
-	regAVGH64  = 0x04
-	regAVGH128 = 0x05
-	regAVGH256 = 0x06
-	regAVGH512 = 0x07
+	avgH4   = 0x00
+	avgH8   = 0x01
+	avgH16  = 0x02
+	avgH32  = 0x03 // default
+	avgH64  = 0x04
+	avgH128 = 0x05
+	avgH256 = 0x06
+	avgH512 = 0x07
 )
 
 // Averaged temperature samples configuration
 const (
-	regAVGT2   = 0x00
-	regAVGT4   = 0x08
-	regAVGT8   = 0x10
-	regAVGT16  = 0x18 // default
-	regAVGT32  = 0x20
-	regAVGT64  = 0x28
-	regAVGT128 = 0x30
-	regAVGT256 = 0x38
+	avgT2   = 0x00
+	avgT4   = 0x01
+	avgT8   = 0x02
+	avgT16  = 0x03 // default
+	avgT32  = 0x04
+	avgT64  = 0x05
+	avgT128 = 0x06
+	avgT256 = 0x07
 )
 
-// Control Reg1
+// Output data rate
 const (
-	regPD       = 0x80 // PowerDown control
-	regBDU      = 0x04 // Block data update control
-	regODROne   = 0x00 // Output data rate: one shot
-	regODR1Hz   = 0x01 // Output data rate: 1 Hz
-	regODR7Hz   = 0x02 // Output data rate: 7 Hz
-	regODR125Hz = 0x03 // Output data rate: 12.5 Hz
+	odrOne   = 0x00 // one shot
+	odr1Hz   = 0x01 // 1 Hz
+	odr7Hz   = 0x02 // 7 Hz
+	odr125Hz = 0x03 // 12.5 Hz
 )
 
-// Status register
-const (
-	regHDA = 0x02 // Humidity Data Available
-	regTDA = 0x01 // Temperature Data Available
+// register fields
+var (
+	fieldAVGH = regmap.Field{Name: "AVGH", Reg: regAVConf, Mask: 0x07} // Averaged humidity samples
+	fieldAVGT = regmap.Field{Name: "AVGT", Reg: regAVConf, Mask: 0x38} // Averaged temperature samples
+	fieldPD   = regmap.Field{Name: "PD", Reg: regCtrl1, Mask: 0x80}    // PowerDown control
+	fieldBDU  = regmap.Field{Name: "BDU", Reg: regCtrl1, Mask: 0x04}   // Block data update control
+	fieldODR  = regmap.Field{Name: "ODR", Reg: regCtrl1, Mask: 0x03}   // Output data rate
+	fieldHDA  = regmap.Field{Name: "H_DA", Reg: regStatus, Mask: 0x02} // Humidity Data Available
+	fieldTDA  = regmap.Field{Name: "T_DA", Reg: regStatus, Mask: 0x01} // Temperature Data Available
 )
 
 // register addresses
 const (
+	regAutoIncr = 0x80 // auto-increment the register address on multi-byte access
+
 	regAVConf       = 0x10
 	regCtrl1        = 0x20
 	regCtrl2        = 0x21
@@ -85,8 +91,9 @@ const (
 
 // Device is a handle to a HTS221 device.
 type Device struct {
-	conn  *smbus.Conn
+	conn  smbus.Bus
 	addr  uint8
+	regs  *regmap.Map
 	calib struct {
 		h0rh uint8
 		h1rh uint8
@@ -101,10 +108,15 @@ type Device struct {
 }
 
 // Open opens a connection to a HTS221 device at the given address.
-func Open(conn *smbus.Conn, addr uint8) (*Device, error) {
+func Open(conn smbus.Bus, addr uint8) (*Device, error) {
 	dev := &Device{
 		conn: conn,
 		addr: addr,
+		regs: regmap.New(conn, addr,
+			regmap.Register{Name: "AV_CONF", Addr: regAVConf},
+			regmap.Register{Name: "CTRL_REG1", Addr: regCtrl1},
+			regmap.Register{Name: "STATUS_REG", Addr: regStatus, Volatile: true},
+		),
 	}
 	err := dev.conn.SetAddr(dev.addr)
 	if err != nil {
@@ -130,17 +142,33 @@ func Open(conn *smbus.Conn, addr uint8) (*Device, error) {
 }
 
 func (dev *Device) powerOn() error {
-	err := dev.conn.WriteReg(dev.addr, regCtrl1, regPD|regODR1Hz)
+	err := dev.regs.SetField(fieldPD, 1)
 	if err != nil {
-		return fmt.Errorf("hts221: power-ON error: %v", err)
+		return fmt.Errorf("hts221: power-ON error: %w", err)
+	}
+	err = dev.regs.SetField(fieldODR, odr1Hz)
+	if err != nil {
+		return fmt.Errorf("hts221: power-ON error: %w", err)
+	}
+	err = dev.regs.Sync()
+	if err != nil {
+		return fmt.Errorf("hts221: power-ON error: %w", err)
 	}
 	return nil
 }
 
 func (dev *Device) configure() error {
-	err := dev.conn.WriteReg(dev.addr, regAVConf, regAVGH32|regAVGT16)
+	err := dev.regs.SetField(fieldAVGH, avgH32)
+	if err != nil {
+		return fmt.Errorf("hts221: configure error: %w", err)
+	}
+	err = dev.regs.SetField(fieldAVGT, avgT16)
+	if err != nil {
+		return fmt.Errorf("hts221: configure error: %w", err)
+	}
+	err = dev.regs.Sync()
 	if err != nil {
-		return fmt.Errorf("hts221: configure error: %v", err)
+		return fmt.Errorf("hts221: configure error: %w", err)
 	}
 	return nil
 }
@@ -148,88 +176,76 @@ func (dev *Device) configure() error {
 func (dev *Device) calibration() error {
 	h0rh, err := dev.conn.ReadReg(dev.addr, regH0_RH_X2)
 	if err != nil {
-		return fmt.Errorf("hts221: calibration error for H0_RH_X2: %v", err)
+		return fmt.Errorf("hts221: calibration error for H0_RH_X2: %w", err)
 	}
 	h1rh, err := dev.conn.ReadReg(dev.addr, regH1_RH_X2)
 	if err != nil {
-		return fmt.Errorf("hts221: calibration error for H1_RH_X2: %v", err)
+		return fmt.Errorf("hts221: calibration error for H1_RH_X2: %w", err)
 	}
 
 	raw, err := dev.conn.ReadReg(dev.addr, regT1_T0_MSB)
 	if err != nil {
-		return fmt.Errorf("hts221: calibration error for T1_T0_MSB: %v", err)
+		return fmt.Errorf("hts221: calibration error for T1_T0_MSB: %w", err)
 	}
 
 	t0, err := dev.conn.ReadReg(dev.addr, regT0_DEGC_X8)
 	if err != nil {
-		return fmt.Errorf("hts221: calibration error for T0_DEGC_X8: %v", err)
+		return fmt.Errorf("hts221: calibration error for T0_DEGC_X8: %w", err)
 	}
 
 	t1, err := dev.conn.ReadReg(dev.addr, regT1_DEGC_X8)
 	if err != nil {
-		return fmt.Errorf("hts221: calibration error for T1_DEGC_X8: %v", err)
+		return fmt.Errorf("hts221: calibration error for T1_DEGC_X8: %w", err)
 	}
 
-	h0t0L, err := dev.conn.ReadReg(dev.addr, regH0_T0_OUT_L)
+	h0t0Out, err := smbus.ReadInt16LE(dev.conn, dev.addr, regH0_T0_OUT_L|regAutoIncr)
 	if err != nil {
-		return fmt.Errorf("hts221: calibration error for H0_T0_OUT_L: %v", err)
+		return fmt.Errorf("hts221: calibration error for H0_T0_OUT: %w", err)
 	}
 
-	h0t0H, err := dev.conn.ReadReg(dev.addr, regH0_T0_OUT_H)
+	h1t0Out, err := smbus.ReadInt16LE(dev.conn, dev.addr, regH1_T0_OUT_L|regAutoIncr)
 	if err != nil {
-		return fmt.Errorf("hts221: calibration error for H0_T0_OUT_H: %v", err)
+		return fmt.Errorf("hts221: calibration error for H1_T0_OUT: %w", err)
 	}
 
-	h1t0L, err := dev.conn.ReadReg(dev.addr, regH1_T0_OUT_L)
+	t0Out, err := smbus.ReadInt16LE(dev.conn, dev.addr, regT0_OUT_L|regAutoIncr)
 	if err != nil {
-		return fmt.Errorf("hts221: calibration error for H1_T0_OUT_L: %v", err)
+		return fmt.Errorf("hts221: calibration error for T0_OUT: %w", err)
 	}
 
-	h1t0H, err := dev.conn.ReadReg(dev.addr, regH1_T0_OUT_H)
+	t1Out, err := smbus.ReadInt16LE(dev.conn, dev.addr, regT1_OUT_L|regAutoIncr)
 	if err != nil {
-		return fmt.Errorf("hts221: calibration error for H1_T0_OUT_H: %v", err)
-	}
-
-	t0L, err := dev.conn.ReadReg(dev.addr, regT0_OUT_L)
-	if err != nil {
-		return fmt.Errorf("hts221: calibration error for T0_OUT_L: %v", err)
-	}
-
-	t0H, err := dev.conn.ReadReg(dev.addr, regT0_OUT_H)
-	if err != nil {
-		return fmt.Errorf("hts221: calibration error for T0_OUT_H: %v", err)
-	}
-
-	t1L, err := dev.conn.ReadReg(dev.addr, regT1_OUT_L)
-	if err != nil {
-		return fmt.Errorf("hts221: calibration error for T1_OUT_L: %v", err)
-	}
-
-	t1H, err := dev.conn.ReadReg(dev.addr, regT1_OUT_H)
-	if err != nil {
-		return fmt.Errorf("hts221: calibration error for T1_OUT_H: %v", err)
+		return fmt.Errorf("hts221: calibration error for T1_OUT: %w", err)
 	}
 
 	dev.calib.h0rh = h0rh
 	dev.calib.h1rh = h1rh
 	dev.calib.t0 = (uint16(raw)&0x3)<<8 | uint16(t0)
 	dev.calib.t1 = (uint16(raw)&0xC)<<6 | uint16(t1)
-	dev.calib.h0t0Out = convI16(h0t0L, h0t0H)
-	dev.calib.h1t0Out = convI16(h1t0L, h1t0H)
-	dev.calib.t0Out = convI16(t0L, t0H)
-	dev.calib.t1Out = convI16(t1L, t1H)
+	dev.calib.h0t0Out = h0t0Out
+	dev.calib.h1t0Out = h1t0Out
+	dev.calib.t0Out = t0Out
+	dev.calib.t1Out = t1Out
 
 	return nil
 }
 
 // Sample return the humidity and temperature as measured by the device.
 func (dev *Device) Sample() (h, t float64, err error) {
-	h, err = dev.humidity()
+	return dev.SampleContext(context.Background())
+}
+
+// SampleContext return the humidity and temperature as measured by the device.
+// SampleContext gives up as soon as ctx is done.
+func (dev *Device) SampleContext(ctx context.Context) (h, t float64, err error) {
+	conn := smbus.WithContext(ctx, dev.conn)
+
+	h, err = dev.humidity(conn)
 	if err != nil {
 		return 0, 0, err
 	}
 
-	t, err = dev.temperature()
+	t, err = dev.temperature(conn)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -237,59 +253,40 @@ func (dev *Device) Sample() (h, t float64, err error) {
 	return h, t, nil
 }
 
-func (dev *Device) humidity() (float64, error) {
-	raw, err := dev.conn.ReadReg(dev.addr, regStatus)
+func (dev *Device) humidity(conn smbus.Bus) (float64, error) {
+	ok, err := dev.regs.WithBus(conn).Field(fieldHDA)
 	if err != nil {
-		return 0, fmt.Errorf("hts221: error reading status register: %v", err)
+		return 0, fmt.Errorf("hts221: error reading status register: %w", err)
 	}
 
-	if raw&regHDA == 0 {
+	if ok == 0 {
 		return math.NaN(), nil
 	}
 
-	hoL, err := dev.conn.ReadReg(dev.addr, regHumidityOutL)
+	h, err := smbus.ReadInt16LE(conn, dev.addr, regHumidityOutL|regAutoIncr)
 	if err != nil {
-		return 0, fmt.Errorf("hts221: error reading HUMIDITY_OUT_L register: %v", err)
+		return 0, fmt.Errorf("hts221: error reading HUMIDITY_OUT register: %w", err)
 	}
-
-	hoH, err := dev.conn.ReadReg(dev.addr, regHumidityOutH)
-	if err != nil {
-		return 0, fmt.Errorf("hts221: error reading HUMIDITY_OUT_H register: %v", err)
-	}
-
-	h := convI16(hoL, hoH)
 	tH0rH := 0.5 * float64(dev.calib.h0rh)
 	tH1rH := 0.5 * float64(dev.calib.h1rh)
 	return tH0rH + (tH1rH-tH0rH)*float64(h-dev.calib.h0t0Out)/float64(dev.calib.h1t0Out-dev.calib.h0t0Out), nil
 }
 
-func (dev *Device) temperature() (float64, error) {
-	raw, err := dev.conn.ReadReg(dev.addr, regStatus)
+func (dev *Device) temperature(conn smbus.Bus) (float64, error) {
+	ok, err := dev.regs.WithBus(conn).Field(fieldTDA)
 	if err != nil {
-		return 0, fmt.Errorf("hts221: error reading status register: %v", err)
+		return 0, fmt.Errorf("hts221: error reading status register: %w", err)
 	}
 
-	if raw&regTDA == 0 {
+	if ok == 0 {
 		return math.NaN(), nil
 	}
 
-	toL, err := dev.conn.ReadReg(dev.addr, regTempOutL)
-	if err != nil {
-		return 0, fmt.Errorf("hts221: error reading TEMPERATURE_OUT_L register: %v", err)
-	}
-
-	toH, err := dev.conn.ReadReg(dev.addr, regTempOutH)
+	t, err := smbus.ReadInt16LE(conn, dev.addr, regTempOutL|regAutoIncr)
 	if err != nil {
-		return 0, fmt.Errorf("hts221: error reading TEMPERATURE_OUT_H register: %v", err)
+		return 0, fmt.Errorf("hts221: error reading TEMPERATURE_OUT register: %w", err)
 	}
-
-	t := convI16(toL, toH)
 	t0 := 0.125 * float64(dev.calib.t0)
 	t1 := 0.125 * float64(dev.calib.t1)
 	return t0 + (t1-t0)*float64(t-dev.calib.t0Out)/float64(dev.calib.t1Out-dev.calib.t0Out), nil
 }
-
-func convI16(lsb, msb uint8) int16 {
-	var buf = [2]byte{lsb, msb}
-	return int16(binary.LittleEndian.Uint16(buf[:]))
-}
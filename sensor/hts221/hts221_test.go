@@ -0,0 +1,53 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hts221_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-daq/smbus/sensor/hts221"
+	"github.com/go-daq/smbus/smbustest"
+)
+
+func TestSample(t *testing.T) {
+	bus := smbustest.New()
+	defer bus.Close()
+
+	dev := &smbustest.Device{RegMask: 0x7f} // MSB of register address is the auto-increment flag
+	dev.MustSet(0x27, 0x03)                 // status: humidity and temperature available
+	dev.MustSet(0x28, 0xf4, 0x01)           // HUMIDITY_OUT = 500
+	dev.MustSet(0x2a, 0xf4, 0x01)           // TEMP_OUT = 500
+	dev.MustSet(0x30, 0x40, 0x80)           // H0_rH_x2 = 64, H1_rH_x2 = 128
+	dev.MustSet(0x32, 0x50, 0xa0)           // T0_degC_x8 = 80, T1_degC_x8 = 160
+	dev.MustSet(0x36, 0x00, 0x00)           // H0_T0_OUT = 0
+	dev.MustSet(0x3a, 0xe8, 0x03)           // H1_T0_OUT = 1000
+	dev.MustSet(0x3c, 0x00, 0x00)           // T0_OUT = 0
+	dev.MustSet(0x3e, 0xe8, 0x03)           // T1_OUT = 1000
+	bus.Attach(hts221.SlaveAddr, dev)
+
+	sensor, err := hts221.Open(bus, hts221.SlaveAddr)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+
+	if got, want := dev.Regs[0x20], uint8(0x81); got != want {
+		t.Fatalf("invalid CTRL_REG1: got=0x%x, want=0x%x", got, want)
+	}
+	if got, want := dev.Regs[0x10], uint8(0x1b); got != want {
+		t.Fatalf("invalid AV_CONF: got=0x%x, want=0x%x", got, want)
+	}
+
+	h, temp, err := sensor.Sample()
+	if err != nil {
+		t.Fatalf("sample error: %v", err)
+	}
+	if want := 48.0; math.Abs(h-want) > 1e-9 {
+		t.Fatalf("invalid humidity: got=%v, want=%v", h, want)
+	}
+	if want := 15.0; math.Abs(temp-want) > 1e-9 {
+		t.Fatalf("invalid temperature: got=%v, want=%v", temp, want)
+	}
+}
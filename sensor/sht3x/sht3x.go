@@ -6,11 +6,13 @@
 package sht3x
 
 import (
+	"context"
 	"errors"
 	"math"
 	"time"
 
 	"github.com/go-daq/smbus"
+	"github.com/go-daq/smbus/internal/xtime"
 )
 
 const (
@@ -46,7 +48,7 @@ var (
 )
 
 // Open opens a connection to a SHT3x-D device at the given address.
-func Open(conn *smbus.Conn, addr uint8) (*Device, error) {
+func Open(conn smbus.Bus, addr uint8) (*Device, error) {
 	var err error
 	dev := Device{
 		conn: conn,
@@ -59,33 +61,44 @@ func Open(conn *smbus.Conn, addr uint8) (*Device, error) {
 
 // Device is a SHT3x-D based device.
 type Device struct {
-	conn *smbus.Conn // connection to smbus
-	addr uint8       // sensor address
+	conn smbus.Bus // connection to smbus
+	addr uint8     // sensor address
 }
 
 func (dev *Device) Close() error {
 	return dev.conn.Close()
 }
 
-func (dev *Device) writeCmd(cmd uint16) error {
-	return dev.conn.WriteReg(dev.addr, uint8(cmd>>8), uint8(cmd&0xFF))
+func (dev *Device) writeCmd(conn smbus.Bus, cmd uint16) error {
+	return conn.WriteReg(dev.addr, uint8(cmd>>8), uint8(cmd&0xFF))
 }
 
 func (dev *Device) ClearStatus() error {
-	return dev.writeCmd(_CLEARSTATUS)
+	return dev.writeCmd(dev.conn, _CLEARSTATUS)
 }
 
 // Sample returns the temperature and the relative humidity from the device.
 func (dev *Device) Sample() (t, rh float64, err error) {
-	err = dev.writeCmd(_MEAS_HIGHREP)
-	if err != nil {
-		return t, rh, err
-	}
-
-	time.Sleep(15 * time.Millisecond)
+	return dev.SampleContext(context.Background())
+}
 
+// SampleContext returns the temperature and the relative humidity from the device.
+// SampleContext gives up as soon as ctx is done.
+func (dev *Device) SampleContext(ctx context.Context) (t, rh float64, err error) {
 	buf := make([]byte, 6)
-	err = dev.conn.ReadBlockData(dev.addr, 0, buf)
+	err = smbus.WithLock(smbus.WithContext(ctx, dev.conn), func(conn smbus.Bus) error {
+		err := dev.writeCmd(conn, _MEAS_HIGHREP)
+		if err != nil {
+			return err
+		}
+
+		err = xtime.Sleep(ctx, 15*time.Millisecond)
+		if err != nil {
+			return err
+		}
+
+		return conn.ReadBlockData(dev.addr, 0, buf)
+	})
 	if err != nil {
 		return t, rh, err
 	}
@@ -0,0 +1,45 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sht3x_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-daq/smbus/sensor/sht3x"
+	"github.com/go-daq/smbus/smbustest"
+)
+
+func TestSample(t *testing.T) {
+	bus := smbustest.New()
+	defer bus.Close()
+
+	dev := &smbustest.Device{}
+	// 0xBEEF has a crc8 of 0x92 (see datasheet).
+	dev.MustSet(0x00, 0xbe, 0xef, 0x92, 0xbe, 0xef, 0x92)
+	bus.Attach(sht3x.I2CAddr, dev)
+
+	sensor, err := sht3x.Open(bus, sht3x.I2CAddr)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+
+	temp, rh, err := sensor.Sample()
+	if err != nil {
+		t.Fatalf("sample error: %v", err)
+	}
+	if want := 175.0*0xbeef/0xffff - 45; math.Abs(temp-want) > 1e-9 {
+		t.Fatalf("invalid temperature: got=%v, want=%v", temp, want)
+	}
+	if want := 100.0 * 0xbeef / 0xffff; math.Abs(rh-want) > 1e-9 {
+		t.Fatalf("invalid humidity: got=%v, want=%v", rh, want)
+	}
+
+	dev.MustSet(0x02, 0x00) // corrupt crc
+	_, _, err = sensor.Sample()
+	if err == nil {
+		t.Fatalf("expected a crc8 error")
+	}
+}
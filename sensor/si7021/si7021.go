@@ -6,9 +6,11 @@
 package si7021
 
 import (
+	"context"
 	"time"
 
 	"github.com/go-daq/smbus"
+	"github.com/go-daq/smbus/internal/xtime"
 )
 
 const (
@@ -27,12 +29,12 @@ const (
 
 // Device is a handle to a SI7021 device
 type Device struct {
-	conn *smbus.Conn
+	conn smbus.Bus
 	addr uint8
 }
 
 // Open opens a connection to a SI7021 device at the given address.
-func Open(conn *smbus.Conn, addr uint8) (*Device, error) {
+func Open(conn smbus.Bus, addr uint8) (*Device, error) {
 	return &Device{
 		conn: conn,
 		addr: addr,
@@ -44,48 +46,68 @@ func (dev *Device) Close() error {
 }
 
 func (dev *Device) Humidity() (float64, error) {
-	err := dev.writeCmd(regRh)
-	if err != nil {
-		return 0, err
-	}
-
-	time.Sleep(300 * time.Millisecond)
+	return dev.HumidityContext(context.Background())
+}
 
-	var data [2]byte
-	_, err = dev.conn.Read(data[:])
+// HumidityContext returns the relative humidity measured by the device.
+// HumidityContext gives up as soon as ctx is done, unless the measurement
+// has already completed.
+func (dev *Device) HumidityContext(ctx context.Context) (float64, error) {
+	data, err := dev.measure(ctx, regRh)
 	if err != nil {
 		return 0, err
 	}
 
 	v := float64((uint16(data[0])*256+uint16(data[1])))*125/65536.0 - 6
-	time.Sleep(300 * time.Millisecond)
+	// the reading is complete: ctx may only cut the settling delay short.
+	_ = xtime.Sleep(ctx, 300*time.Millisecond)
 	return v, nil
 }
 
 func (dev *Device) Temperature() (float64, error) {
-	err := dev.writeCmd(regTmp)
-	if err != nil {
-		return 0, err
-	}
-
-	time.Sleep(300 * time.Millisecond)
+	return dev.TemperatureContext(context.Background())
+}
 
-	var data [2]byte
-	_, err = dev.conn.Read(data[:])
+// TemperatureContext returns the temperature measured by the device.
+// TemperatureContext gives up as soon as ctx is done, unless the measurement
+// has already completed.
+func (dev *Device) TemperatureContext(ctx context.Context) (float64, error) {
+	data, err := dev.measure(ctx, regTmp)
 	if err != nil {
 		return 0, err
 	}
 
 	v := float64((uint16(data[0])*256+uint16(data[1])))*175.72/65536.0 - 46.85
-	time.Sleep(300 * time.Millisecond)
+	// the reading is complete: ctx may only cut the settling delay short.
+	_ = xtime.Sleep(ctx, 300*time.Millisecond)
 	return v, nil
 }
 
-func (dev *Device) writeCmd(cmd uint8) error {
-	err := dev.conn.SetAddr(dev.addr)
+// measure sends the measurement command cmd and reads back the result.
+func (dev *Device) measure(ctx context.Context, cmd uint8) ([2]byte, error) {
+	var data [2]byte
+	err := smbus.WithLock(smbus.WithContext(ctx, dev.conn), func(conn smbus.Bus) error {
+		err := dev.writeCmd(conn, cmd)
+		if err != nil {
+			return err
+		}
+
+		err = xtime.Sleep(ctx, 300*time.Millisecond)
+		if err != nil {
+			return err
+		}
+
+		_, err = conn.Read(data[:])
+		return err
+	})
+	return data, err
+}
+
+func (dev *Device) writeCmd(conn smbus.Bus, cmd uint8) error {
+	err := conn.SetAddr(dev.addr)
 	if err != nil {
 		return err
 	}
-	_, err = dev.conn.WriteByte(cmd)
+	_, err = conn.Write([]byte{cmd})
 	return err
 }
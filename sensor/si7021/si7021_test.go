@@ -0,0 +1,100 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package si7021_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-daq/smbus/sensor/si7021"
+	"github.com/go-daq/smbus/smbustest"
+)
+
+func TestHumidityTemperature(t *testing.T) {
+	bus := smbustest.New()
+	defer bus.Close()
+
+	const addr = 0x40
+	dev := &smbustest.Device{}
+	dev.MustSet(0xf5, 0x80, 0x00) // humidity, no hold master mode
+	dev.MustSet(0xf3, 0x66, 0x00) // temperature, no hold master mode
+	bus.Attach(addr, dev)
+
+	sensor, err := si7021.Open(bus, addr)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+
+	h, err := sensor.Humidity()
+	if err != nil {
+		t.Fatalf("humidity error: %v", err)
+	}
+	if want := 0x8000*125/65536.0 - 6; math.Abs(h-want) > 1e-9 {
+		t.Fatalf("invalid humidity: got=%v, want=%v", h, want)
+	}
+
+	temp, err := sensor.Temperature()
+	if err != nil {
+		t.Fatalf("temperature error: %v", err)
+	}
+	if want := 0x6600*175.72/65536.0 - 46.85; math.Abs(temp-want) > 1e-9 {
+		t.Fatalf("invalid temperature: got=%v, want=%v", temp, want)
+	}
+}
+
+func TestHumidityContext(t *testing.T) {
+	bus := smbustest.New()
+	defer bus.Close()
+
+	const addr = 0x40
+	bus.Attach(addr, &smbustest.Device{})
+
+	sensor, err := si7021.Open(bus, addr)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = sensor.HumidityContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > 200*time.Millisecond {
+		t.Fatalf("humidity measurement not interrupted (took %v)", d)
+	}
+}
+
+func TestTemperatureContextSettling(t *testing.T) {
+	bus := smbustest.New()
+	defer bus.Close()
+
+	const addr = 0x40
+	dev := &smbustest.Device{}
+	dev.MustSet(0xf3, 0x66, 0x00) // temperature, no hold master mode
+	bus.Attach(addr, dev)
+
+	sensor, err := si7021.Open(bus, addr)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+
+	// ctx expires after the measurement, during the settling delay.
+	ctx, cancel := context.WithTimeout(context.Background(), 400*time.Millisecond)
+	defer cancel()
+
+	temp, err := sensor.TemperatureContext(ctx)
+	if err != nil {
+		t.Fatalf("temperature error: %v", err)
+	}
+	if want := 0x6600*175.72/65536.0 - 46.85; math.Abs(temp-want) > 1e-9 {
+		t.Fatalf("invalid temperature: got=%v, want=%v", temp, want)
+	}
+}
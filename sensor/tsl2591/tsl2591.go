@@ -6,10 +6,13 @@
 package tsl2591
 
 import (
+	"context"
 	"math"
 	"time"
 
 	"github.com/go-daq/smbus"
+	"github.com/go-daq/smbus/internal/xtime"
+	"github.com/go-daq/smbus/regmap"
 )
 
 // IntegTimeValue describes the integration time used while extracting data
@@ -35,35 +38,39 @@ const (
 	GainMax  GainValue = 0x30 // Maximum gain (9876x)
 )
 
+// register fields
+var (
+	fieldPON   = regmap.Field{Name: "PON", Reg: CmdBit | RegEnable, Mask: EnablePowerON}
+	fieldAEN   = regmap.Field{Name: "AEN", Reg: CmdBit | RegEnable, Mask: EnableAEN}
+	fieldAIEN  = regmap.Field{Name: "AIEN", Reg: CmdBit | RegEnable, Mask: EnableAIEN}
+	fieldATIME = regmap.Field{Name: "ATIME", Reg: CmdBit | RegControl, Mask: 0x07}
+	fieldAGAIN = regmap.Field{Name: "AGAIN", Reg: CmdBit | RegControl, Mask: 0x30}
+)
+
 // Device is a TSL2591 sensor.
 type Device struct {
-	conn  *smbus.Conn // connection to smbus
-	addr  uint8       // sensor address
-	integ uint8       // integration time in ms
-	gain  uint8
+	conn  smbus.Bus      // connection to smbus
+	addr  uint8          // sensor address
+	regs  *regmap.Map    // configuration registers
+	integ IntegTimeValue // integration time, as configured
+	gain  GainValue      // gain, as configured
 }
 
 // Open opens a connection to the TSL2591 sensor device at address addr
 // on the provided SMBus.
-func Open(conn *smbus.Conn, addr uint8, integ IntegTimeValue, gain GainValue) (*Device, error) {
+func Open(conn smbus.Bus, addr uint8, integ IntegTimeValue, gain GainValue) (*Device, error) {
 	var err error
 
 	dev := Device{
 		conn: conn,
 		addr: addr,
+		regs: regmap.New(conn, addr,
+			regmap.Register{Name: "ENABLE", Addr: CmdBit | RegEnable},
+			regmap.Register{Name: "CONTROL", Addr: CmdBit | RegControl},
+		),
 	}
 
-	err = dev.setTiming(integ)
-	if err != nil {
-		return nil, err
-	}
-
-	err = dev.setGain(gain)
-	if err != nil {
-		return nil, err
-	}
-
-	err = dev.disable()
+	err = dev.configure(integ, gain)
 	if err != nil {
 		return nil, err
 	}
@@ -76,67 +83,59 @@ func (dev *Device) Close() error {
 }
 
 func (dev *Device) enable() error {
-	return dev.conn.WriteReg(
-		dev.addr,
-		CmdBit|RegEnable,
-		EnablePowerON|EnableAEN|EnableAIEN,
-	)
+	return dev.setPower(dev.regs, 1)
 }
 
 func (dev *Device) disable() error {
-	return dev.conn.WriteReg(dev.addr, CmdBit|RegEnable, EnablePowerOFF)
+	return dev.setPower(dev.regs, 0)
+}
+
+func (dev *Device) setPower(regs *regmap.Map, v uint8) error {
+	for _, f := range []regmap.Field{fieldPON, fieldAEN, fieldAIEN} {
+		err := regs.SetField(f, v)
+		if err != nil {
+			return err
+		}
+	}
+	return regs.Sync()
 }
 
-func (dev *Device) setTiming(integ IntegTimeValue) error {
+// configure sets the integration time and the gain of the device,
+// which is left powered off.
+func (dev *Device) configure(integ IntegTimeValue, gain GainValue) error {
 	err := dev.enable()
 	if err != nil {
 		return err
 	}
 
-	dev.integ = uint8(integ)
-
-	err = dev.conn.WriteReg(
-		dev.addr,
-		CmdBit|RegControl,
-		dev.integ|dev.gain,
-	)
+	err = dev.regs.SetField(fieldATIME, uint8(integ))
 	if err != nil {
 		return err
 	}
 
-	return dev.disable()
-}
-
-func (dev *Device) setGain(gain GainValue) error {
-	var err error
-
-	err = dev.enable()
+	err = dev.regs.SetField(fieldAGAIN, uint8(gain)>>4)
 	if err != nil {
 		return err
 	}
 
-	dev.gain = uint8(gain)
-
-	err = dev.conn.WriteReg(
-		dev.addr,
-		CmdBit|RegControl,
-		dev.integ|dev.gain,
-	)
+	err = dev.regs.Sync()
 	if err != nil {
 		return err
 	}
+	dev.integ = integ
+	dev.gain = gain
 
 	return dev.disable()
 }
 
 // Gain returns the gain register value.
 func (dev *Device) Gain() GainValue {
-	return GainValue(dev.gain)
+	return dev.gain
 }
 
 // Timing returns the integration time register value.
 func (dev *Device) Timing() IntegTimeValue {
-	return IntegTimeValue(dev.integ)
+	return dev.integ
 }
 
 func (dev *Device) Lux(full, ir uint16) float64 {
@@ -146,7 +145,7 @@ func (dev *Device) Lux(full, ir uint16) float64 {
 	}
 
 	atime := 100.0
-	switch IntegTimeValue(dev.integ) {
+	switch dev.Timing() {
 	case IntegTime100ms:
 		atime = 100.0
 	case IntegTime200ms:
@@ -162,7 +161,7 @@ func (dev *Device) Lux(full, ir uint16) float64 {
 	}
 
 	again := 1.0
-	switch GainValue(dev.gain) {
+	switch dev.Gain() {
 	case GainLow:
 		again = 1
 	case GainMed:
@@ -181,26 +180,46 @@ func (dev *Device) Lux(full, ir uint16) float64 {
 }
 
 func (dev *Device) FullLuminosity() (uint16, uint16, error) {
-	err := dev.enable()
-	if err != nil {
-		return 0, 0, err
+	return dev.FullLuminosityContext(context.Background())
+}
+
+// FullLuminosityContext returns the full spectrum and infrared luminosities
+// measured by the device.
+// FullLuminosityContext gives up as soon as ctx is done.
+func (dev *Device) FullLuminosityContext(ctx context.Context) (uint16, uint16, error) {
+	var (
+		conn     = smbus.WithContext(ctx, dev.conn)
+		full, ir uint16
+	)
+
+	err := dev.setPower(dev.regs.WithBus(conn), 1)
+	if err == nil {
+		full, ir, err = dev.sample(ctx, conn)
+	}
+
+	// power the device off, even when ctx is done.
+	if derr := dev.disable(); err == nil {
+		err = derr
 	}
-	time.Sleep(((120*time.Duration(dev.integ))*time.Millisecond + 1) * time.Second)
 	if err != nil {
 		return 0, 0, err
 	}
+	return full, ir, nil
+}
 
-	full, err := dev.conn.ReadWord(dev.addr, CmdBit|RegChan0Low)
+// sample waits for the end of the integration and reads the luminosities.
+func (dev *Device) sample(ctx context.Context, conn smbus.Bus) (uint16, uint16, error) {
+	err := xtime.Sleep(ctx, ((120*time.Duration(dev.Timing()))*time.Millisecond+1)*time.Second)
 	if err != nil {
 		return 0, 0, err
 	}
 
-	ir, err := dev.conn.ReadWord(dev.addr, CmdBit|RegChan1Low)
+	full, err := conn.ReadWord(dev.addr, CmdBit|RegChan0Low)
 	if err != nil {
 		return 0, 0, err
 	}
 
-	err = dev.disable()
+	ir, err := conn.ReadWord(dev.addr, CmdBit|RegChan1Low)
 	if err != nil {
 		return 0, 0, err
 	}
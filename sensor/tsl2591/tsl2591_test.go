@@ -0,0 +1,71 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tsl2591_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-daq/smbus/sensor/tsl2591"
+	"github.com/go-daq/smbus/smbustest"
+)
+
+func TestFullLuminosity(t *testing.T) {
+	bus := smbustest.New()
+	defer bus.Close()
+
+	dev := &smbustest.Device{}
+	dev.MustSet(tsl2591.CmdBit|tsl2591.RegChan0Low, 0x34, 0x12)
+	dev.MustSet(tsl2591.CmdBit|tsl2591.RegChan1Low, 0x78, 0x06)
+	bus.Attach(tsl2591.Addr, dev)
+
+	sensor, err := tsl2591.Open(bus, tsl2591.Addr, tsl2591.IntegTime100ms, tsl2591.GainMed)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+
+	if got, want := sensor.Gain(), tsl2591.GainMed; got != want {
+		t.Fatalf("invalid gain: got=0x%x, want=0x%x", got, want)
+	}
+	if got, want := dev.Regs[tsl2591.CmdBit|tsl2591.RegControl], uint8(tsl2591.GainMed); got != want {
+		t.Fatalf("invalid control register: got=0x%x, want=0x%x", got, want)
+	}
+	if got, want := dev.Regs[tsl2591.CmdBit|tsl2591.RegEnable], tsl2591.EnablePowerOFF; got != want {
+		t.Fatalf("invalid enable register: got=0x%x, want=0x%x", got, want)
+	}
+
+	full, ir, err := sensor.FullLuminosity()
+	if err != nil {
+		t.Fatalf("full-luminosity error: %v", err)
+	}
+	if full != 0x1234 || ir != 0x0678 {
+		t.Fatalf("invalid luminosity: got=(0x%x, 0x%x), want=(0x1234, 0x0678)", full, ir)
+	}
+}
+
+func TestFullLuminosityContext(t *testing.T) {
+	bus := smbustest.New()
+	defer bus.Close()
+
+	dev := &smbustest.Device{}
+	bus.Attach(tsl2591.Addr, dev)
+
+	sensor, err := tsl2591.Open(bus, tsl2591.Addr, tsl2591.IntegTime100ms, tsl2591.GainMed)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, _, err = sensor.FullLuminosityContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("invalid error: got=%v, want=%v", err, context.DeadlineExceeded)
+	}
+	if got, want := dev.Regs[tsl2591.CmdBit|tsl2591.RegEnable], tsl2591.EnablePowerOFF; got != want {
+		t.Fatalf("device left powered on: got=0x%x, want=0x%x", got, want)
+	}
+}
@@ -8,65 +8,207 @@
 package smbus
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sync"
 	"syscall"
+	"time"
 	"unsafe"
 )
 
 const (
+	i2cRetries    = 0x0701
+	i2cTimeout    = 0x0702
 	i2cSlave      = 0x0703
 	i2cSlaveForce = 0x0706
 	i2cFuncs      = 0x0705
+	i2cRdWr       = 0x0707
+	i2cPEC        = 0x0708
 	i2cSMBus      = 0x0720
 
 	i2cSMBusWrite uint8 = 0
 	i2cSMBusRead  uint8 = 1
 
 	// size identifiers
-	i2cSMBusByteData     uint32 = 2
-	i2cSMBusWordData     uint32 = 3
-	i2cSMBusBlockData    uint32 = 5
-	i2cSMBusI2CBlockData uint32 = 8
-	i2cSMBusBlockMax     uint32 = 32
+	i2cSMBusQuick         uint32 = 0
+	i2cSMBusByte          uint32 = 1
+	i2cSMBusByteData      uint32 = 2
+	i2cSMBusWordData      uint32 = 3
+	i2cSMBusProcCall      uint32 = 4
+	i2cSMBusBlockData     uint32 = 5
+	i2cSMBusBlockProcCall uint32 = 7
+	i2cSMBusI2CBlockData  uint32 = 8
+	i2cSMBusBlockMax      uint32 = 32
 )
 
 var (
 	errSMBusBlockDataMax = errors.New("smbus: buffer slice too big")
+	errSMBusBlockCount   = errors.New("smbus: invalid block byte count")
 )
 
+// device is the low-level interface to an i2c adapter.
+type device interface {
+	io.ReadWriteCloser
+	ioctl(req, arg uintptr) error                 // ioctl with an integer argument
+	ioctlPtr(req uintptr, p unsafe.Pointer) error // ioctl with a pointer argument
+}
+
+// devFile is an i2c adapter accessed through its i2c-dev character device.
+type devFile struct {
+	*os.File
+}
+
+func (f devFile) ioctl(req, arg uintptr) error {
+	return ioctl(f.Fd(), req, arg)
+}
+
+func (f devFile) ioctlPtr(req uintptr, p unsafe.Pointer) error {
+	return ioctl(f.Fd(), req, uintptr(p))
+}
+
 // Conn is connection to a i2c device.
+//
+// Conn is safe for concurrent use: selecting the address of a device and
+// performing a transaction with that device is atomic.
+// Multi-step sequences should be performed with WithLock.
 type Conn struct {
-	f *os.File
+	*conn
+	held bool            // whether the lock of conn is held by the caller
+	ctx  context.Context // context of the operations, if any
+}
+
+// conn is the state of a connection, shared by a Conn and its locked views.
+type conn struct {
+	mu     sync.Mutex
+	f      device
+	bus    int    // i2c bus number, or -1 if unknown
+	force  bool   // whether to use I2C_SLAVE_FORCE to select devices
+	funcs  *Funcs // adapter functionalities, lazily queried
+	slave  Addr   // address of the currently selected device
+	bound  bool   // whether slave is the address bound to the adapter
+	tenbit bool   // whether 10-bit addressing is enabled
+	pec    bool   // whether Packet Error Checking is enabled
+	retry  RetryPolicy
+	tracer Tracer // receives all transactions, if not nil
+}
+
+// config holds configuration options for a Conn.
+type config struct {
+	Force   bool
+	Timeout time.Duration // kernel adapter timeout, if non-zero
+	Retries *int          // kernel adapter retries, if set
+	Retry   RetryPolicy
+	Tracer  Tracer
+}
+
+// Force configures whether devices should be selected even if they are
+// already in use by a kernel driver.
+//
+// Forcing access to a device bound to a kernel driver is dangerous:
+// the kernel driver and the Conn may interfere.
+func Force(v bool) func(cfg *config) {
+	return func(cfg *config) {
+		cfg.Force = v
+	}
 }
 
 // OpenFile opens a connection to the i2c bus number.
 // Users should call SetAddr afterwards to have a properly configured SMBus connection.
-func OpenFile(bus int) (*Conn, error) {
-	f, err := os.OpenFile(fmt.Sprintf("/dev/i2c-%d", bus), os.O_RDWR, 0600)
+func OpenFile(bus int, opts ...func(cfg *config)) (*Conn, error) {
+	return OpenPath(devPath(bus), opts...)
+}
+
+// Open opens a connection to the i2c bus number at address addr.
+func Open(bus int, addr uint8, opts ...func(cfg *config)) (*Conn, error) {
+	c, err := OpenFile(bus, opts...)
 	if err != nil {
 		return nil, err
 	}
-	return &Conn{f: f}, nil
+	if err := c.addr("set-addr", addr, -1); err != nil {
+		c.f.Close()
+		return nil, err
+	}
+	return c, nil
 }
 
-// Open opens a connection to the i2c bus number at address addr.
-func Open(bus int, addr uint8) (*Conn, error) {
-	f, err := os.OpenFile(fmt.Sprintf("/dev/i2c-%d", bus), os.O_RDWR, 0600)
+// OpenPath opens a connection to the i2c-dev character device at path,
+// e.g. "/dev/i2c-1" or a udev symlink such as "/dev/i2c-sensors".
+// Users should call SetAddr afterwards to have a properly configured SMBus connection.
+func OpenPath(path string, opts ...func(cfg *config)) (*Conn, error) {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0600)
 	if err != nil {
 		return nil, err
 	}
-	if err := ioctl(f.Fd(), i2cSlave, uintptr(addr)); err != nil {
-		return nil, err
+	c := &Conn{conn: &conn{f: devFile{f}, bus: pathBus(path), force: cfg.Force, retry: cfg.Retry, tracer: cfg.Tracer}}
+
+	if cfg.Timeout != 0 {
+		if err := c.SetTimeout(cfg.Timeout); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if cfg.Retries != nil {
+		if err := c.SetRetries(*cfg.Retries); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func devPath(bus int) string {
+	return fmt.Sprintf("/dev/i2c-%d", bus)
+}
+
+// pathBus returns the bus number of the i2c-dev character device at path,
+// following symlinks, or -1 if it can not be inferred.
+func pathBus(path string) int {
+	if dst, err := filepath.EvalSymlinks(path); err == nil {
+		path = dst
 	}
-	return &Conn{f: f}, nil
+	n, ok := busNumber(filepath.Base(path))
+	if !ok {
+		return -1
+	}
+	return n
 }
 
 // Write sends buf to the remote i2c device.
 // The interpretation of the message is implementation dependant.
+//
+// When PEC is enabled, the Packet Error Code is appended to buf.
 func (c *Conn) Write(buf []byte) (int, error) {
-	return c.f.Write(buf)
+	c.lock()
+	defer c.unlock()
+
+	if err := c.context().Err(); err != nil {
+		return 0, c.wrap("write", c.slave, -1, err)
+	}
+	var (
+		n   int
+		err error
+	)
+	ev := c.begin("write", c.slave, -1)
+	if c.pec {
+		n, err = c.writePEC(buf)
+	} else {
+		n, err = c.f.Write(buf)
+	}
+	err = c.wrap("write", c.slave, -1, err)
+	if ev != nil {
+		ev.Write = append([]byte(nil), buf[:n]...)
+		c.end(ev, err)
+	}
+	return n, err
 }
 
 // WriteByte sends a single byte to the remote i2c device.
@@ -74,22 +216,136 @@ func (c *Conn) Write(buf []byte) (int, error) {
 func (c *Conn) WriteByte(b byte) (int, error) {
 	var buf [1]byte
 	buf[0] = b
-	return c.f.Write(buf[:])
+	return c.Write(buf[:])
 }
 
 // Read reads data from the remote i2c device into p.
+//
+// When PEC is enabled, an additional Packet Error Code byte is read
+// and checked against the received data.
 func (c *Conn) Read(p []byte) (int, error) {
-	return c.f.Read(p)
+	c.lock()
+	defer c.unlock()
+
+	if err := c.context().Err(); err != nil {
+		return 0, c.wrap("read", c.slave, -1, err)
+	}
+	var (
+		n   int
+		err error
+	)
+	ev := c.begin("read", c.slave, -1)
+	if c.pec {
+		n, err = c.readPEC(p)
+	} else {
+		n, err = c.f.Read(p)
+	}
+	err = c.wrap("read", c.slave, -1, err)
+	if ev != nil {
+		ev.Read = append([]byte(nil), p[:n]...)
+		c.end(ev, err)
+	}
+	return n, err
 }
 
 // Close closes the connection to the remote i2c device.
 func (c *Conn) Close() error {
+	c.lock()
+	defer c.unlock()
+
 	return c.f.Close()
 }
 
+// WriteQuick sends a SMBus quick command to the device at address addr.
+// The bit value (0 or 1) is sent in place of the read/write bit.
+func (c *Conn) WriteQuick(addr, bit uint8) error {
+	c.lock()
+	defer c.unlock()
+
+	if err := c.check("write-quick", Addr7(addr), -1, FuncSMBusQuick); err != nil {
+		return err
+	}
+
+	if err := c.addr("write-quick", addr, -1); err != nil {
+		return err
+	}
+
+	cmd := i2cCmd{
+		rw:  bit & 1,
+		len: i2cSMBusQuick,
+	}
+	return c.smbus(&cmd)
+}
+
+// SendByte sends a single byte v to the device at address addr,
+// without designating a register.
+func (c *Conn) SendByte(addr, v uint8) error {
+	c.lock()
+	defer c.unlock()
+
+	if err := c.check("send-byte", Addr7(addr), -1, FuncSMBusWriteByte); err != nil {
+		return err
+	}
+
+	if err := c.addr("send-byte", addr, -1); err != nil {
+		return err
+	}
+
+	cmd := i2cCmd{
+		rw:  i2cSMBusWrite,
+		cmd: v,
+		len: i2cSMBusByte,
+	}
+	return c.smbus(&cmd)
+}
+
+// ReceiveByte reads a single byte from the device at address addr,
+// without designating a register.
+func (c *Conn) ReceiveByte(addr uint8) (uint8, error) {
+	c.lock()
+	defer c.unlock()
+
+	if err := c.check("receive-byte", Addr7(addr), -1, FuncSMBusReadByte); err != nil {
+		return 0, err
+	}
+
+	if err := c.addr("receive-byte", addr, -1); err != nil {
+		return 0, err
+	}
+
+	var v uint8
+	cmd := i2cCmd{
+		rw:  i2cSMBusRead,
+		len: i2cSMBusByte,
+		ptr: unsafe.Pointer(&v),
+	}
+	err := c.smbus(&cmd)
+	return v, err
+}
+
 // ReadReg reads a single byte from a designated register.
 func (c *Conn) ReadReg(addr, reg uint8) (uint8, error) {
-	if err := c.addr(addr); err != nil {
+	return c.readReg(Addr7(addr), reg)
+}
+
+// ReadRegAt is like ReadReg, for the device at the 7-bit or 10-bit address a.
+// ReadRegAt returns an error if a is out of range or is reserved.
+func (c *Conn) ReadRegAt(a Addr, reg uint8) (uint8, error) {
+	if err := a.Validate(); err != nil {
+		return 0, err
+	}
+	return c.readReg(a, reg)
+}
+
+func (c *Conn) readReg(a Addr, reg uint8) (uint8, error) {
+	c.lock()
+	defer c.unlock()
+
+	if err := c.check("read-reg", a, int(reg), FuncSMBusReadByteData); err != nil {
+		return 0, err
+	}
+
+	if err := c.selectAddr("read-reg", a, int(reg)); err != nil {
 		return 0, err
 	}
 
@@ -100,14 +356,33 @@ func (c *Conn) ReadReg(addr, reg uint8) (uint8, error) {
 		len: i2cSMBusByteData,
 		ptr: unsafe.Pointer(&v),
 	}
-	ptr := unsafe.Pointer(&cmd)
-	err := ioctl(c.f.Fd(), i2cSMBus, uintptr(ptr))
+	err := c.smbus(&cmd)
 	return v, err
 }
 
 // WriteReg writes a single byte v to a designated register.
 func (c *Conn) WriteReg(addr, reg, v uint8) error {
-	if err := c.addr(addr); err != nil {
+	return c.writeReg(Addr7(addr), reg, v)
+}
+
+// WriteRegAt is like WriteReg, for the device at the 7-bit or 10-bit address a.
+// WriteRegAt returns an error if a is out of range or is reserved.
+func (c *Conn) WriteRegAt(a Addr, reg, v uint8) error {
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	return c.writeReg(a, reg, v)
+}
+
+func (c *Conn) writeReg(a Addr, reg, v uint8) error {
+	c.lock()
+	defer c.unlock()
+
+	if err := c.check("write-reg", a, int(reg), FuncSMBusWriteByteData); err != nil {
+		return err
+	}
+
+	if err := c.selectAddr("write-reg", a, int(reg)); err != nil {
 		return err
 	}
 
@@ -117,13 +392,32 @@ func (c *Conn) WriteReg(addr, reg, v uint8) error {
 		len: i2cSMBusByteData,
 		ptr: unsafe.Pointer(&v),
 	}
-	ptr := unsafe.Pointer(&cmd)
-	return ioctl(c.f.Fd(), i2cSMBus, uintptr(ptr))
+	return c.smbus(&cmd)
 }
 
 // ReadWord reads a 2-bytes word from a designated register.
 func (c *Conn) ReadWord(addr, reg uint8) (uint16, error) {
-	if err := c.addr(addr); err != nil {
+	return c.readWord(Addr7(addr), reg)
+}
+
+// ReadWordAt is like ReadWord, for the device at the 7-bit or 10-bit address a.
+// ReadWordAt returns an error if a is out of range or is reserved.
+func (c *Conn) ReadWordAt(a Addr, reg uint8) (uint16, error) {
+	if err := a.Validate(); err != nil {
+		return 0, err
+	}
+	return c.readWord(a, reg)
+}
+
+func (c *Conn) readWord(a Addr, reg uint8) (uint16, error) {
+	c.lock()
+	defer c.unlock()
+
+	if err := c.check("read-word", a, int(reg), FuncSMBusReadWordData); err != nil {
+		return 0, err
+	}
+
+	if err := c.selectAddr("read-word", a, int(reg)); err != nil {
 		return 0, err
 	}
 
@@ -134,14 +428,33 @@ func (c *Conn) ReadWord(addr, reg uint8) (uint16, error) {
 		len: i2cSMBusWordData,
 		ptr: unsafe.Pointer(&v),
 	}
-	ptr := unsafe.Pointer(&cmd)
-	err := ioctl(c.f.Fd(), i2cSMBus, uintptr(ptr))
+	err := c.smbus(&cmd)
 	return v, err
 }
 
 // WriteWord writes a 2-bytes word v to a designated register.
 func (c *Conn) WriteWord(addr, reg uint8, v uint16) error {
-	if err := c.addr(addr); err != nil {
+	return c.writeWord(Addr7(addr), reg, v)
+}
+
+// WriteWordAt is like WriteWord, for the device at the 7-bit or 10-bit address a.
+// WriteWordAt returns an error if a is out of range or is reserved.
+func (c *Conn) WriteWordAt(a Addr, reg uint8, v uint16) error {
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	return c.writeWord(a, reg, v)
+}
+
+func (c *Conn) writeWord(a Addr, reg uint8, v uint16) error {
+	c.lock()
+	defer c.unlock()
+
+	if err := c.check("write-word", a, int(reg), FuncSMBusWriteWordData); err != nil {
+		return err
+	}
+
+	if err := c.selectAddr("write-word", a, int(reg)); err != nil {
 		return err
 	}
 
@@ -151,17 +464,45 @@ func (c *Conn) WriteWord(addr, reg uint8, v uint16) error {
 		len: i2cSMBusWordData,
 		ptr: unsafe.Pointer(&v),
 	}
-	ptr := unsafe.Pointer(&cmd)
-	return ioctl(c.f.Fd(), i2cSMBus, uintptr(ptr))
+	return c.smbus(&cmd)
 }
 
 // ReadBlockData reads len(buf) data into the byte slice, from the designated register.
+//
+// ReadBlockData performs an i2c block read and is equivalent to ReadI2CBlockData.
+// See ReadSMBusBlock for a SMBus block read.
 func (c *Conn) ReadBlockData(addr, reg uint8, buf []byte) error {
+	return c.ReadI2CBlockData(addr, reg, buf)
+}
+
+// ReadI2CBlockData reads len(buf) data into the byte slice, from the designated register.
+// The number of bytes to read is chosen by the caller, up to 32 bytes.
+func (c *Conn) ReadI2CBlockData(addr, reg uint8, buf []byte) error {
+	return c.readI2CBlockData(Addr7(addr), reg, buf)
+}
+
+// ReadI2CBlockDataAt is like ReadI2CBlockData, for the device at the 7-bit or 10-bit address a.
+// ReadI2CBlockDataAt returns an error if a is out of range or is reserved.
+func (c *Conn) ReadI2CBlockDataAt(a Addr, reg uint8, buf []byte) error {
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	return c.readI2CBlockData(a, reg, buf)
+}
+
+func (c *Conn) readI2CBlockData(a Addr, reg uint8, buf []byte) error {
+	c.lock()
+	defer c.unlock()
+
 	if len(buf) > int(i2cSMBusBlockMax) {
 		return errSMBusBlockDataMax
 	}
 
-	if err := c.addr(addr); err != nil {
+	if err := c.check("read-i2c-block-data", a, int(reg), FuncSMBusReadI2CBlock); err != nil {
+		return err
+	}
+
+	if err := c.selectAddr("read-i2c-block-data", a, int(reg)); err != nil {
 		return err
 	}
 
@@ -173,8 +514,7 @@ func (c *Conn) ReadBlockData(addr, reg uint8, buf []byte) error {
 		len: i2cSMBusI2CBlockData,
 		ptr: unsafe.Pointer(&data[0]),
 	}
-	ptr := unsafe.Pointer(&cmd)
-	err := ioctl(c.f.Fd(), i2cSMBus, uintptr(ptr))
+	err := c.smbus(&cmd)
 	if err != nil {
 		return err
 	}
@@ -184,12 +524,41 @@ func (c *Conn) ReadBlockData(addr, reg uint8, buf []byte) error {
 }
 
 // WriteBlockData writes the buf byte slice to a designated register.
+//
+// WriteBlockData performs an i2c block write and is equivalent to WriteI2CBlockData.
+// See WriteSMBusBlock for a SMBus block write.
 func (c *Conn) WriteBlockData(addr, reg uint8, buf []byte) error {
+	return c.WriteI2CBlockData(addr, reg, buf)
+}
+
+// WriteI2CBlockData writes the buf byte slice to a designated register.
+// No byte count is sent to the device.
+func (c *Conn) WriteI2CBlockData(addr, reg uint8, buf []byte) error {
+	return c.writeI2CBlockData(Addr7(addr), reg, buf)
+}
+
+// WriteI2CBlockDataAt is like WriteI2CBlockData, for the device at the 7-bit or 10-bit address a.
+// WriteI2CBlockDataAt returns an error if a is out of range or is reserved.
+func (c *Conn) WriteI2CBlockDataAt(a Addr, reg uint8, buf []byte) error {
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	return c.writeI2CBlockData(a, reg, buf)
+}
+
+func (c *Conn) writeI2CBlockData(a Addr, reg uint8, buf []byte) error {
+	c.lock()
+	defer c.unlock()
+
 	if len(buf) > int(i2cSMBusBlockMax) {
 		return errSMBusBlockDataMax
 	}
 
-	if err := c.addr(addr); err != nil {
+	if err := c.check("write-i2c-block-data", a, int(reg), FuncSMBusWriteI2CBlock); err != nil {
+		return err
+	}
+
+	if err := c.selectAddr("write-i2c-block-data", a, int(reg)); err != nil {
 		return err
 	}
 
@@ -203,16 +572,189 @@ func (c *Conn) WriteBlockData(addr, reg uint8, buf []byte) error {
 		len: i2cSMBusI2CBlockData,
 		ptr: unsafe.Pointer(&data[0]),
 	}
-	ptr := unsafe.Pointer(&cmd)
-	return ioctl(c.f.Fd(), i2cSMBus, uintptr(ptr))
+	return c.smbus(&cmd)
+}
+
+// ReadSMBusBlock performs a SMBus block read from the designated register.
+// The number of bytes, up to 32, is chosen by the device.
+func (c *Conn) ReadSMBusBlock(addr, reg uint8) ([]byte, error) {
+	c.lock()
+	defer c.unlock()
+
+	if err := c.check("read-smbus-block", Addr7(addr), int(reg), FuncSMBusReadBlock); err != nil {
+		return nil, err
+	}
+
+	if err := c.addr("read-smbus-block", addr, int(reg)); err != nil {
+		return nil, err
+	}
+
+	var data [i2cSMBusBlockMax + 2]byte
+	cmd := i2cCmd{
+		rw:  i2cSMBusRead,
+		cmd: reg,
+		len: i2cSMBusBlockData,
+		ptr: unsafe.Pointer(&data[0]),
+	}
+	err := c.smbus(&cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	n := int(data[0])
+	if n > int(i2cSMBusBlockMax) {
+		return nil, errSMBusBlockCount
+	}
+	buf := make([]byte, n)
+	copy(buf, data[1:1+n])
+	return buf, nil
+}
+
+// WriteSMBusBlock performs a SMBus block write of the buf byte slice to
+// the designated register.
+// The byte count is sent to the device before the data.
+func (c *Conn) WriteSMBusBlock(addr, reg uint8, buf []byte) error {
+	c.lock()
+	defer c.unlock()
+
+	if len(buf) > int(i2cSMBusBlockMax) {
+		return errSMBusBlockDataMax
+	}
+
+	if err := c.check("write-smbus-block", Addr7(addr), int(reg), FuncSMBusWriteBlock); err != nil {
+		return err
+	}
+
+	if err := c.addr("write-smbus-block", addr, int(reg)); err != nil {
+		return err
+	}
+
+	var data [i2cSMBusBlockMax + 2]byte
+	data[0] = byte(len(buf))
+	copy(data[1:], buf)
+
+	cmd := i2cCmd{
+		rw:  i2cSMBusWrite,
+		cmd: reg,
+		len: i2cSMBusBlockData,
+		ptr: unsafe.Pointer(&data[0]),
+	}
+	return c.smbus(&cmd)
+}
+
+// ProcessCall sends the 2-bytes word v to the designated register and
+// reads back a 2-bytes word from the device.
+func (c *Conn) ProcessCall(addr, reg uint8, v uint16) (uint16, error) {
+	c.lock()
+	defer c.unlock()
+
+	if err := c.check("process-call", Addr7(addr), int(reg), FuncSMBusProcCall); err != nil {
+		return 0, err
+	}
+
+	if err := c.addr("process-call", addr, int(reg)); err != nil {
+		return 0, err
+	}
+
+	cmd := i2cCmd{
+		rw:  i2cSMBusWrite,
+		cmd: reg,
+		len: i2cSMBusProcCall,
+		ptr: unsafe.Pointer(&v),
+	}
+	err := c.smbus(&cmd)
+	return v, err
+}
+
+// BlockProcessCall sends the buf byte slice to the designated register and
+// reads back a block of data from the device.
+// The number of bytes sent plus the number of bytes read back can not exceed 32.
+func (c *Conn) BlockProcessCall(addr, reg uint8, buf []byte) ([]byte, error) {
+	c.lock()
+	defer c.unlock()
+
+	if len(buf) > int(i2cSMBusBlockMax) {
+		return nil, errSMBusBlockDataMax
+	}
+
+	if err := c.check("block-process-call", Addr7(addr), int(reg), FuncSMBusBlockProcCall); err != nil {
+		return nil, err
+	}
+
+	if err := c.addr("block-process-call", addr, int(reg)); err != nil {
+		return nil, err
+	}
+
+	var data [i2cSMBusBlockMax + 2]byte
+	data[0] = byte(len(buf))
+	copy(data[1:], buf)
+
+	cmd := i2cCmd{
+		rw:  i2cSMBusWrite,
+		cmd: reg,
+		len: i2cSMBusBlockProcCall,
+		ptr: unsafe.Pointer(&data[0]),
+	}
+	err := c.smbus(&cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	n := int(data[0])
+	if n > int(i2cSMBusBlockMax) {
+		return nil, errSMBusBlockCount
+	}
+	out := make([]byte, n)
+	copy(out, data[1:1+n])
+	return out, nil
 }
 
-func (c *Conn) addr(addr uint8) error {
-	return ioctl(c.f.Fd(), i2cSlave, uintptr(addr))
+// addr binds the 7-bit address addr to the adapter, for the operation op
+// on the designated register reg.
+func (c *Conn) addr(op string, addr uint8, reg int) error {
+	return c.selectAddr(op, Addr7(addr), reg)
+}
+
+// smbus performs the SMBus transaction described by cmd.
+// smbus retries failed transactions according to the retry policy of c.
+func (c *Conn) smbus(cmd *i2cCmd) error {
+	var ev *Event
+	if c.tracer != nil {
+		op, reg := opName(cmd)
+		ev = c.begin(op, c.slave, reg)
+		switch {
+		case cmd.len == i2cSMBusByte && cmd.rw == i2cSMBusWrite:
+			ev.Write = []byte{cmd.cmd}
+		case cmd.rw == i2cSMBusWrite:
+			ev.Write = payload(cmd)
+		}
+	}
+
+	err := c.retry.do(c.context(), func() error {
+		return c.f.ioctlPtr(i2cSMBus, unsafe.Pointer(cmd))
+	})
+	if err == syscall.EBADMSG && c.pec {
+		err = &PECError{Addr: c.slave, Err: err}
+	}
+	if err != nil {
+		op, reg := opName(cmd)
+		err = c.wrap(op, c.slave, reg, err)
+	}
+
+	if ev != nil {
+		if err == nil && (cmd.rw == i2cSMBusRead || cmd.len == i2cSMBusProcCall || cmd.len == i2cSMBusBlockProcCall) {
+			ev.Read = payload(cmd)
+		}
+		c.end(ev, err)
+	}
+	return err
 }
 
 func (c *Conn) SetAddr(addr uint8) error {
-	return c.addr(addr)
+	c.lock()
+	defer c.unlock()
+
+	return c.addr("set-addr", addr, -1)
 }
 
 func ioctl(fd, cmd, arg uintptr) (err error) {
@@ -5,8 +5,15 @@
 package smbus_test
 
 import (
+	"context"
+	"errors"
+	"os"
 	"os/user"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/go-daq/smbus"
 )
@@ -56,3 +63,197 @@ func TestOpenFile(t *testing.T) {
 	}
 	t.Logf("v=%v\n", v)
 }
+
+func TestFuncsString(t *testing.T) {
+	for _, tc := range []struct {
+		fs   smbus.Funcs
+		want string
+	}{
+		{0, "0"},
+		{smbus.FuncI2C, "I2C"},
+		{smbus.FuncSMBusQuick | smbus.FuncSMBusPEC, "SMBUS_PEC|SMBUS_QUICK"},
+		{smbus.FuncSMBusByte, "SMBUS_READ_BYTE|SMBUS_WRITE_BYTE"},
+		{smbus.FuncSMBusHostNotify | 0x80000000, "SMBUS_HOST_NOTIFY|0x80000000"},
+	} {
+		t.Run(tc.want, func(t *testing.T) {
+			got := tc.fs.String()
+			if got != tc.want {
+				t.Fatalf("got=%q, want=%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFuncsHas(t *testing.T) {
+	fs := smbus.FuncI2C | smbus.FuncSMBusByteData
+	if !fs.Has(smbus.FuncSMBusReadByteData) {
+		t.Fatalf("%v should provide %v", fs, smbus.FuncSMBusReadByteData)
+	}
+	if fs.Has(smbus.FuncSMBusByteData | smbus.FuncSMBusQuick) {
+		t.Fatalf("%v should not provide %v", fs, smbus.FuncSMBusByteData|smbus.FuncSMBusQuick)
+	}
+}
+
+func TestPEC(t *testing.T) {
+	for _, tc := range []struct {
+		buf  []byte
+		want uint8
+	}{
+		{nil, 0x00},
+		{[]byte("123456789"), 0xf4},
+		{[]byte{0x01}, 0x07},
+	} {
+		got := smbus.PEC(tc.buf)
+		if got != tc.want {
+			t.Errorf("PEC(%x): got=0x%02x, want=0x%02x", tc.buf, got, tc.want)
+		}
+	}
+}
+
+func TestBusyError(t *testing.T) {
+	root := t.TempDir()
+	defer smbus.SetSysfs(root)()
+
+	dir := filepath.Join(root, "bus", "i2c", "devices", "1-0076")
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink("../../../../bus/i2c/drivers/bmp280", filepath.Join(dir, "driver"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := smbus.BoundDriver(1, smbus.Addr7(0x76)), "bmp280"; got != want {
+		t.Fatalf("invalid bound driver: got=%q, want=%q", got, want)
+	}
+	if got, want := smbus.BoundDriver(1, smbus.Addr7(0x77)), ""; got != want {
+		t.Fatalf("invalid bound driver: got=%q, want=%q", got, want)
+	}
+
+	err = &smbus.BusyError{Bus: 1, Addr: 0x76, Driver: "bmp280"}
+	if !errors.Is(err, syscall.EBUSY) {
+		t.Fatalf("busy error should wrap EBUSY")
+	}
+	if got, want := err.Error(), `smbus: device 0x76 on bus 1 is in use by kernel driver "bmp280"`; got != want {
+		t.Fatalf("invalid error message:\ngot= %s\nwant=%s", got, want)
+	}
+}
+
+func TestAddr(t *testing.T) {
+	for _, tc := range []struct {
+		addr     smbus.Addr
+		str      string
+		tenbit   bool
+		value    uint16
+		reserved bool
+		valid    bool
+	}{
+		{smbus.Addr7(0x00), "0x00", false, 0x00, true, false},
+		{smbus.Addr7(0x07), "0x07", false, 0x07, true, false},
+		{smbus.Addr7(0x08), "0x08", false, 0x08, false, true},
+		{smbus.Addr7(0x76), "0x76", false, 0x76, false, true},
+		{smbus.Addr7(0x77), "0x77", false, 0x77, false, true},
+		{smbus.Addr7(0x78), "0x78", false, 0x78, true, false},
+		{smbus.Addr7(0x80), "0x80", false, 0x80, false, false},
+		{smbus.Addr10(0x000), "0x000(10-bit)", true, 0x000, false, true},
+		{smbus.Addr10(0x3a2), "0x3a2(10-bit)", true, 0x3a2, false, true},
+		{smbus.Addr10(0x400), "0x400(10-bit)", true, 0x400, false, false},
+	} {
+		t.Run(tc.str, func(t *testing.T) {
+			if got := tc.addr.String(); got != tc.str {
+				t.Fatalf("invalid string: got=%q, want=%q", got, tc.str)
+			}
+			if got := tc.addr.TenBit(); got != tc.tenbit {
+				t.Fatalf("invalid 10-bit flag: got=%v, want=%v", got, tc.tenbit)
+			}
+			if got := tc.addr.Value(); got != tc.value {
+				t.Fatalf("invalid value: got=0x%x, want=0x%x", got, tc.value)
+			}
+			if got := tc.addr.Reserved(); got != tc.reserved {
+				t.Fatalf("invalid reserved flag: got=%v, want=%v", got, tc.reserved)
+			}
+			if err := tc.addr.Validate(); (err == nil) != tc.valid {
+				t.Fatalf("invalid validation: err=%v, want valid=%v", err, tc.valid)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	errPerm := errors.New("permanent")
+	for _, tc := range []struct {
+		name   string
+		policy smbus.RetryPolicy
+		errs   []error
+		calls  int
+		want   error
+	}{
+		{"no-retry", smbus.RetryPolicy{}, []error{syscall.EIO, nil}, 1, syscall.EIO},
+		{"success", smbus.RetryPolicy{Count: 3}, []error{nil}, 1, nil},
+		{"transient", smbus.RetryPolicy{Count: 3}, []error{syscall.EIO, syscall.EAGAIN, nil}, 3, nil},
+		{"exhausted", smbus.RetryPolicy{Count: 2}, []error{syscall.EIO, syscall.EIO, syscall.EIO, nil}, 3, syscall.EIO},
+		{"permanent", smbus.RetryPolicy{Count: 3}, []error{errPerm, nil}, 1, errPerm},
+		{"not-retryable", smbus.RetryPolicy{Count: 3}, []error{syscall.ENXIO, nil}, 1, syscall.ENXIO},
+		{"errnos", smbus.RetryPolicy{Count: 3, Errnos: []syscall.Errno{syscall.ENXIO}}, []error{syscall.ENXIO, nil}, 2, nil},
+		{"pec", smbus.RetryPolicy{Count: 1, Backoff: time.Millisecond}, []error{&smbus.PECError{Err: syscall.EBADMSG}, nil}, 2, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			err := tc.policy.Do(context.Background(), func() error {
+				err := tc.errs[calls]
+				calls++
+				return err
+			})
+			if err != tc.want {
+				t.Fatalf("invalid error: got=%v, want=%v", err, tc.want)
+			}
+			if calls != tc.calls {
+				t.Fatalf("invalid number of calls: got=%d, want=%d", calls, tc.calls)
+			}
+		})
+	}
+}
+
+func TestOpenPath(t *testing.T) {
+	dir := t.TempDir()
+	fname := filepath.Join(dir, "i2c-7")
+	err := os.WriteFile(fname, nil, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink(fname, filepath.Join(dir, "i2c-sensors"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := smbus.OpenPath(filepath.Join(dir, "i2c-sensors"))
+	if err != nil {
+		t.Fatalf("open-path error: %v", err)
+	}
+	defer c.Close()
+
+	if got, want := c.BusNumber(), 7; got != want {
+		t.Fatalf("invalid bus number: got=%d, want=%d", got, want)
+	}
+
+	// a regular file is not an i2c-dev character device.
+	err = c.SetAddr(0x76)
+	if !errors.Is(err, syscall.ENOTTY) {
+		t.Fatalf("invalid set-addr error: got=%v, want=%v", err, syscall.ENOTTY)
+	}
+
+	_, err = smbus.OpenPath(fname, smbus.Retries(-1))
+	if err == nil || !strings.Contains(err.Error(), "invalid number of retries") {
+		t.Fatalf("invalid open-path error for negative retries: %v", err)
+	}
+	_, err = smbus.OpenPath(fname, smbus.Timeout(-time.Second))
+	if err == nil || !strings.Contains(err.Error(), "invalid timeout") {
+		t.Fatalf("invalid open-path error for a negative timeout: %v", err)
+	}
+
+	_, err = smbus.OpenPath(filepath.Join(dir, "not-there"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("invalid open-path error: got=%v, want=%v", err, os.ErrNotExist)
+	}
+}
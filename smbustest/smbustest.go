@@ -0,0 +1,467 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package smbustest provides an in-memory simulated SMBus, to test
+// sensor drivers without root access nor real hardware.
+//
+// Fake devices are attached to the simulated bus at a given address.
+// Each device exposes a 256-bytes register map, addressed through an
+// internal register pointer: SMBus register operations (ReadReg, WriteWord,
+// ReadBlockData, ...) set the pointer to the designated register,
+// raw writes use their first byte as the new pointer value, and every
+// transferred byte auto-increments the pointer.
+//
+// Sessions against real hardware can be captured with a Recorder into a
+// transcript, and later replayed with a Replay bus, to regression-test
+// drivers against real devices.
+package smbustest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/go-daq/smbus"
+)
+
+const blockMax = 32
+
+var (
+	errBlockDataMax = errors.New("smbustest: buffer slice too big")
+)
+
+// Device is a fake SMBus device, described by its register map.
+type Device struct {
+	Regs [256]uint8 // register map
+
+	// ReadHook, if not nil, is called before register reg is read.
+	// ReadHook may modify the register map of the device.
+	// A non-nil error aborts the current transaction.
+	ReadHook func(dev *Device, reg uint8) error
+
+	// WriteHook, if not nil, is called before v is stored into register reg.
+	// WriteHook may modify the register map of the device.
+	// A non-nil error aborts the current transaction.
+	WriteHook func(dev *Device, reg, v uint8) error
+
+	// Busy simulates a device in use by a kernel driver:
+	// selecting the device fails with syscall.EBUSY.
+	Busy bool
+
+	// RegMask, if not zero, is applied to the register pointer before
+	// accessing the register map. RegMask simulates devices using the
+	// upper bits of the register address as flags, such as an
+	// auto-increment bit.
+	RegMask uint8
+
+	ptr uint8 // register pointer
+}
+
+// Set stores the bytes vs into consecutive registers, starting at reg.
+// Set returns an error, and stores nothing, if vs runs past register 0xff.
+func (dev *Device) Set(reg uint8, vs ...uint8) error {
+	if int(reg)+len(vs) > len(dev.Regs) {
+		return fmt.Errorf("smbustest: %d bytes from register 0x%02x overflow the register map", len(vs), reg)
+	}
+	copy(dev.Regs[reg:], vs)
+	return nil
+}
+
+// MustSet is like Set but panics if vs runs past register 0xff.
+// MustSet is intended for setting up test fixtures.
+func (dev *Device) MustSet(reg uint8, vs ...uint8) {
+	err := dev.Set(reg, vs...)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// reg returns the register designated by the register pointer.
+func (dev *Device) reg() uint8 {
+	if dev.RegMask != 0 {
+		return dev.ptr & dev.RegMask
+	}
+	return dev.ptr
+}
+
+func (dev *Device) read(p []byte) error {
+	for i := range p {
+		reg := dev.reg()
+		if dev.ReadHook != nil {
+			err := dev.ReadHook(dev, reg)
+			if err != nil {
+				return err
+			}
+		}
+		p[i] = dev.Regs[reg]
+		dev.ptr++
+	}
+	return nil
+}
+
+func (dev *Device) write(p []byte) error {
+	for _, v := range p {
+		reg := dev.reg()
+		if dev.WriteHook != nil {
+			err := dev.WriteHook(dev, reg, v)
+			if err != nil {
+				return err
+			}
+		}
+		dev.Regs[reg] = v
+		dev.ptr++
+	}
+	return nil
+}
+
+// Bus is a simulated SMBus.
+// Bus implements the smbus.Bus interface.
+//
+// Transactions targeting an address with no attached device fail
+// with syscall.ENXIO, as the Linux i2c-dev interface does.
+// Bus can be scanned with smbus.Scan.
+type Bus struct {
+	mu     sync.Mutex
+	addr   uint8
+	devs   map[uint8]*Device
+	closed bool
+}
+
+// New returns a new simulated bus, with no device attached.
+func New() *Bus {
+	return &Bus{devs: make(map[uint8]*Device)}
+}
+
+// Attach attaches the device dev at address addr, replacing any device
+// previously attached at that address.
+func (b *Bus) Attach(addr uint8, dev *Device) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.devs[addr] = dev
+}
+
+// Detach removes the device attached at address addr, if any.
+func (b *Bus) Detach(addr uint8) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.devs, addr)
+}
+
+// Device returns the device attached at address addr, or nil.
+func (b *Bus) Device(addr uint8) *Device {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.devs[addr]
+}
+
+// device returns the device at address addr.
+// device must be called with b.mu held.
+func (b *Bus) device(addr uint8) (*Device, error) {
+	if b.closed {
+		return nil, os.ErrClosed
+	}
+	dev, ok := b.devs[addr]
+	if !ok {
+		return nil, syscall.ENXIO
+	}
+	if dev.Busy {
+		return nil, syscall.EBUSY
+	}
+	return dev, nil
+}
+
+// SetAddr sets the address of the device targeted by Read and Write.
+func (b *Bus) SetAddr(addr uint8) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return os.ErrClosed
+	}
+	if dev, ok := b.devs[addr]; ok && dev.Busy {
+		return syscall.EBUSY
+	}
+	b.addr = addr
+	return nil
+}
+
+// Read reads data from the current device into p, starting at the
+// current register pointer.
+func (b *Bus) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	dev, err := b.device(b.addr)
+	if err != nil {
+		return 0, err
+	}
+	err = dev.read(p)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Write sends p to the current device.
+// The first byte of p sets the register pointer, the remaining bytes are
+// written into consecutive registers.
+func (b *Bus) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	dev, err := b.device(b.addr)
+	if err != nil {
+		return 0, err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	dev.ptr = p[0]
+	err = dev.write(p[1:])
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close closes the simulated bus.
+func (b *Bus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return os.ErrClosed
+	}
+	b.closed = true
+	return nil
+}
+
+func (b *Bus) readRegs(addr, reg uint8, p []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	dev, err := b.device(addr)
+	if err != nil {
+		return err
+	}
+	b.addr = addr
+	dev.ptr = reg
+	return dev.read(p)
+}
+
+func (b *Bus) writeRegs(addr, reg uint8, p []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	dev, err := b.device(addr)
+	if err != nil {
+		return err
+	}
+	b.addr = addr
+	dev.ptr = reg
+	return dev.write(p)
+}
+
+// WriteQuick sends a SMBus quick command to the device at address addr.
+// WriteQuick succeeds if a device is attached at addr.
+func (b *Bus) WriteQuick(addr, bit uint8) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, err := b.device(addr)
+	if err != nil {
+		return err
+	}
+	b.addr = addr
+	return nil
+}
+
+// SendByte sends a single byte v to the device at address addr.
+// The byte v sets the register pointer of the device.
+func (b *Bus) SendByte(addr, v uint8) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	dev, err := b.device(addr)
+	if err != nil {
+		return err
+	}
+	b.addr = addr
+	dev.ptr = v
+	return nil
+}
+
+// ReceiveByte reads a single byte from the device at address addr,
+// at the current register pointer.
+func (b *Bus) ReceiveByte(addr uint8) (uint8, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	dev, err := b.device(addr)
+	if err != nil {
+		return 0, err
+	}
+	b.addr = addr
+	var buf [1]byte
+	err = dev.read(buf[:])
+	return buf[0], err
+}
+
+// ReadReg reads a single byte from a designated register.
+func (b *Bus) ReadReg(addr, reg uint8) (uint8, error) {
+	var buf [1]byte
+	err := b.readRegs(addr, reg, buf[:])
+	return buf[0], err
+}
+
+// WriteReg writes a single byte v to a designated register.
+func (b *Bus) WriteReg(addr, reg, v uint8) error {
+	return b.writeRegs(addr, reg, []byte{v})
+}
+
+// ReadWord reads a 2-bytes word from a designated register.
+// As mandated by SMBus, the low byte is read first.
+func (b *Bus) ReadWord(addr, reg uint8) (uint16, error) {
+	var buf [2]byte
+	err := b.readRegs(addr, reg, buf[:])
+	return uint16(buf[0]) | uint16(buf[1])<<8, err
+}
+
+// WriteWord writes a 2-bytes word v to a designated register.
+// As mandated by SMBus, the low byte is written first.
+func (b *Bus) WriteWord(addr, reg uint8, v uint16) error {
+	return b.writeRegs(addr, reg, []byte{uint8(v), uint8(v >> 8)})
+}
+
+// ReadBlockData reads len(buf) data into the byte slice, from the designated register.
+// ReadBlockData is equivalent to ReadI2CBlockData.
+func (b *Bus) ReadBlockData(addr, reg uint8, buf []byte) error {
+	return b.ReadI2CBlockData(addr, reg, buf)
+}
+
+// WriteBlockData writes the buf byte slice to a designated register.
+// WriteBlockData is equivalent to WriteI2CBlockData.
+func (b *Bus) WriteBlockData(addr, reg uint8, buf []byte) error {
+	return b.WriteI2CBlockData(addr, reg, buf)
+}
+
+// ReadI2CBlockData reads len(buf) data into the byte slice, from the designated register.
+func (b *Bus) ReadI2CBlockData(addr, reg uint8, buf []byte) error {
+	if len(buf) > blockMax {
+		return errBlockDataMax
+	}
+	return b.readRegs(addr, reg, buf)
+}
+
+// WriteI2CBlockData writes the buf byte slice to a designated register.
+func (b *Bus) WriteI2CBlockData(addr, reg uint8, buf []byte) error {
+	if len(buf) > blockMax {
+		return errBlockDataMax
+	}
+	return b.writeRegs(addr, reg, buf)
+}
+
+// ReadSMBusBlock performs a SMBus block read from the designated register.
+// The designated register holds the byte count, followed by the data.
+// Byte counts larger than 32 fail with syscall.EPROTO.
+func (b *Bus) ReadSMBusBlock(addr, reg uint8) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	dev, err := b.device(addr)
+	if err != nil {
+		return nil, err
+	}
+	b.addr = addr
+	dev.ptr = reg
+
+	var n [1]byte
+	err = dev.read(n[:])
+	if err != nil {
+		return nil, err
+	}
+	if n[0] > blockMax {
+		return nil, syscall.EPROTO
+	}
+	buf := make([]byte, n[0])
+	err = dev.read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// WriteSMBusBlock performs a SMBus block write of the buf byte slice to
+// the designated register.
+// The byte count is stored in the designated register, followed by the data.
+func (b *Bus) WriteSMBusBlock(addr, reg uint8, buf []byte) error {
+	if len(buf) > blockMax {
+		return errBlockDataMax
+	}
+	data := make([]byte, 1+len(buf))
+	data[0] = byte(len(buf))
+	copy(data[1:], buf)
+	return b.writeRegs(addr, reg, data)
+}
+
+// ProcessCall writes the 2-bytes word v to the designated register and
+// reads back the 2-bytes word stored at that register.
+// Devices emulate the computation of the reply with a ReadHook.
+func (b *Bus) ProcessCall(addr, reg uint8, v uint16) (uint16, error) {
+	err := b.WriteWord(addr, reg, v)
+	if err != nil {
+		return 0, err
+	}
+	return b.ReadWord(addr, reg)
+}
+
+// BlockProcessCall writes the buf byte slice to the designated register,
+// as WriteSMBusBlock does, and reads back a block of data, as ReadSMBusBlock does.
+// Devices emulate the computation of the reply with a ReadHook.
+func (b *Bus) BlockProcessCall(addr, reg uint8, buf []byte) ([]byte, error) {
+	err := b.WriteSMBusBlock(addr, reg, buf)
+	if err != nil {
+		return nil, err
+	}
+	return b.ReadSMBusBlock(addr, reg)
+}
+
+// Transfer executes the messages msgs as a single combined transaction.
+// Write messages set the register pointer of the addressed device with
+// their first byte and write the remaining bytes into consecutive registers.
+// Read messages read from the current register pointer.
+// Transactions targeting an absent device fail with syscall.ENXIO.
+func (b *Bus) Transfer(msgs ...smbus.Msg) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, msg := range msgs {
+		if msg.Addr.TenBit() || msg.Addr.Value() > 0x7f {
+			return syscall.ENXIO
+		}
+		dev, err := b.device(uint8(msg.Addr))
+		if err != nil {
+			return err
+		}
+		b.addr = uint8(msg.Addr)
+		switch {
+		case msg.Flags&smbus.MsgRead != 0:
+			err = dev.read(msg.Buf)
+		case len(msg.Buf) > 0:
+			dev.ptr = msg.Buf[0]
+			err = dev.write(msg.Buf[1:])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tx writes w to the device at address addr and then reads len(r) bytes into r.
+func (b *Bus) Tx(addr uint8, w, r []byte) error {
+	msgs := make([]smbus.Msg, 0, 2)
+	if len(w) > 0 {
+		msgs = append(msgs, smbus.Msg{Addr: smbus.Addr7(addr), Buf: w})
+	}
+	if len(r) > 0 {
+		msgs = append(msgs, smbus.Msg{Addr: smbus.Addr7(addr), Flags: smbus.MsgRead, Buf: r})
+	}
+	return b.Transfer(msgs...)
+}
+
+var (
+	_ smbus.Bus = (*Bus)(nil)
+)
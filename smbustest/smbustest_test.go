@@ -0,0 +1,232 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbustest_test
+
+import (
+	"errors"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/go-daq/smbus"
+	"github.com/go-daq/smbus/smbustest"
+)
+
+func TestBus(t *testing.T) {
+	bus := smbustest.New()
+	defer bus.Close()
+
+	dev := &smbustest.Device{}
+	err := dev.Set(0x10, 0x01, 0x02, 0x03, 0x04)
+	if err != nil {
+		t.Fatalf("set error: %v", err)
+	}
+	err = dev.Set(0xfe, 0x01, 0x02, 0x03)
+	if err == nil {
+		t.Fatalf("expected an error for a write past register 0xff")
+	}
+	if got := dev.Regs[0xfe]; got != 0 {
+		t.Fatalf("set should not store anything on error: reg[0xfe]=0x%x", got)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("expected a panic for a must-set past register 0xff")
+			}
+		}()
+		dev.MustSet(0xff, 0x01, 0x02)
+	}()
+	bus.Attach(0x42, dev)
+
+	v, err := bus.ReadReg(0x42, 0x10)
+	if err != nil {
+		t.Fatalf("read-reg error: %v", err)
+	}
+	if v != 0x01 {
+		t.Fatalf("read-reg: got=0x%x, want=0x01", v)
+	}
+
+	w, err := bus.ReadWord(0x42, 0x11)
+	if err != nil {
+		t.Fatalf("read-word error: %v", err)
+	}
+	if w != 0x0302 {
+		t.Fatalf("read-word: got=0x%x, want=0x0302", w)
+	}
+
+	err = bus.WriteBlockData(0x42, 0x20, []byte{0xa, 0xb, 0xc})
+	if err != nil {
+		t.Fatalf("write-block error: %v", err)
+	}
+	if got, want := dev.Regs[0x20:0x23], []byte{0xa, 0xb, 0xc}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("write-block: got=%v, want=%v", got, want)
+	}
+
+	// raw write sets the register pointer, raw read auto-increments it.
+	err = bus.SetAddr(0x42)
+	if err != nil {
+		t.Fatalf("set-addr error: %v", err)
+	}
+	_, err = bus.Write([]byte{0x12})
+	if err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	buf := make([]byte, 2)
+	_, err = bus.Read(buf)
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	if want := []byte{0x03, 0x04}; !reflect.DeepEqual(buf, want) {
+		t.Fatalf("read: got=%v, want=%v", buf, want)
+	}
+
+	_, err = bus.ReadReg(0x43, 0x00)
+	if !errors.Is(err, syscall.ENXIO) {
+		t.Fatalf("read-reg on absent device: got=%v, want=%v", err, syscall.ENXIO)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	bus := smbustest.New()
+	defer bus.Close()
+
+	errBusy := errors.New("busy")
+	dev := &smbustest.Device{
+		ReadHook: func(dev *smbustest.Device, reg uint8) error {
+			dev.Regs[reg]++ // free-running counter
+			return nil
+		},
+		WriteHook: func(dev *smbustest.Device, reg, v uint8) error {
+			if reg == 0xff {
+				return errBusy
+			}
+			return nil
+		},
+	}
+	bus.Attach(0x10, dev)
+
+	for i := 1; i < 4; i++ {
+		v, err := bus.ReadReg(0x10, 0x00)
+		if err != nil {
+			t.Fatalf("read-reg error: %v", err)
+		}
+		if v != uint8(i) {
+			t.Fatalf("read-reg: got=%d, want=%d", v, i)
+		}
+	}
+
+	err := bus.WriteReg(0x10, 0xff, 1)
+	if err != errBusy {
+		t.Fatalf("write-reg: got=%v, want=%v", err, errBusy)
+	}
+}
+
+func TestSMBusBlock(t *testing.T) {
+	bus := smbustest.New()
+	defer bus.Close()
+
+	dev := &smbustest.Device{}
+	bus.Attach(0x0b, dev)
+
+	want := []byte("bq40z50")
+	err := bus.WriteSMBusBlock(0x0b, 0x21, want)
+	if err != nil {
+		t.Fatalf("write-smbus-block error: %v", err)
+	}
+	if got := dev.Regs[0x21]; got != uint8(len(want)) {
+		t.Fatalf("invalid byte count: got=%d, want=%d", got, len(want))
+	}
+
+	got, err := bus.ReadSMBusBlock(0x0b, 0x21)
+	if err != nil {
+		t.Fatalf("read-smbus-block error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("read-smbus-block: got=%q, want=%q", got, want)
+	}
+
+	dev.MustSet(0x40, 33)
+	_, err = bus.ReadSMBusBlock(0x0b, 0x40)
+	if !errors.Is(err, syscall.EPROTO) {
+		t.Fatalf("read-smbus-block with invalid count: got=%v, want=%v", err, syscall.EPROTO)
+	}
+}
+
+func TestProcessCall(t *testing.T) {
+	bus := smbustest.New()
+	defer bus.Close()
+
+	// device replies with the 1-complement of the word it received.
+	dev := &smbustest.Device{
+		ReadHook: func(dev *smbustest.Device, reg uint8) error {
+			if reg == 0x01 {
+				dev.Regs[0x01] = ^dev.Regs[0x01]
+				dev.Regs[0x02] = ^dev.Regs[0x02]
+			}
+			return nil
+		},
+	}
+	bus.Attach(0x0b, dev)
+
+	err := bus.WriteQuick(0x0b, 0)
+	if err != nil {
+		t.Fatalf("write-quick error: %v", err)
+	}
+	err = bus.WriteQuick(0x0c, 0)
+	if !errors.Is(err, syscall.ENXIO) {
+		t.Fatalf("write-quick on absent device: got=%v, want=%v", err, syscall.ENXIO)
+	}
+
+	v, err := bus.ProcessCall(0x0b, 0x01, 0x1234)
+	if err != nil {
+		t.Fatalf("process-call error: %v", err)
+	}
+	if want := ^uint16(0x1234); v != want {
+		t.Fatalf("process-call: got=0x%x, want=0x%x", v, want)
+	}
+
+	err = bus.SendByte(0x0b, 0x02)
+	if err != nil {
+		t.Fatalf("send-byte error: %v", err)
+	}
+	b, err := bus.ReceiveByte(0x0b)
+	if err != nil {
+		t.Fatalf("receive-byte error: %v", err)
+	}
+	if want := ^uint8(0x12); b != want {
+		t.Fatalf("receive-byte: got=0x%x, want=0x%x", b, want)
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	bus := smbustest.New()
+	defer bus.Close()
+
+	dev := &smbustest.Device{}
+	for i := range dev.Regs {
+		dev.Regs[i] = uint8(i)
+	}
+	bus.Attach(0x50, dev)
+
+	// reads longer than 32 bytes are allowed.
+	buf := make([]byte, 64)
+	err := bus.Tx(0x50, []byte{0x80}, buf)
+	if err != nil {
+		t.Fatalf("tx error: %v", err)
+	}
+	for i, v := range buf {
+		if v != uint8(0x80+i) {
+			t.Fatalf("tx: buf[%d]=0x%x, want=0x%x", i, v, 0x80+i)
+		}
+	}
+
+	err = bus.Transfer(
+		smbus.Msg{Addr: 0x50, Buf: []byte{0x00, 0xaa}},
+		smbus.Msg{Addr: 0x51, Flags: smbus.MsgRead, Buf: buf[:1]},
+	)
+	if !errors.Is(err, syscall.ENXIO) {
+		t.Fatalf("transfer to absent device: got=%v, want=%v", err, syscall.ENXIO)
+	}
+}
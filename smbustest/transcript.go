@@ -0,0 +1,599 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbustest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/go-daq/smbus"
+)
+
+// Transcripts
+//
+// A transcript is a text file holding a sequence of bus transactions, one
+// per line, preceded by the header line:
+//
+//	# smbus transcript v1
+//
+// Each transaction line holds the name of the operation, followed by
+// space-separated key=value fields, in this order:
+//
+//	addr=0x76    address of the device (always present)
+//	reg=0xd0     designated register
+//	n=24         number of bytes requested by a read
+//	w=3f         data sent to the device, in hexadecimal, in bus order
+//	r=60         data received from the device, in hexadecimal, in bus order
+//	err=ENXIO    error of the transaction, until the end of the line
+//
+// Operations are named after the methods of smbus.Bus: set-addr, read,
+// write, close, read-reg, write-reg, read-word, write-word,
+// read-block-data and write-block-data.
+// Errors are recorded as errno names (e.g. ENXIO, ETIMEDOUT), as the
+// kinds of the well-known errors they match, or as a quoted message.
+// The kinds are "unsupported", "nack", "timeout", "busy", "canceled" and
+// "deadline-exceeded", for smbus.ErrUnsupported, smbus.ErrNACK,
+// smbus.ErrTimeout, smbus.ErrBusBusy, context.Canceled and
+// context.DeadlineExceeded; an error matching several of them is recorded
+// as their '+'-separated list (e.g. timeout+deadline-exceeded).
+// Replayed errors match the same well-known errors as recorded ones.
+// Other lines starting with '#' and blank lines are ignored.
+const transcriptHeader = "# smbus transcript v1"
+
+// entry is a transaction of a transcript.
+type entry struct {
+	op   string
+	addr uint8
+	reg  int    // designated register, or -1
+	n    int    // number of bytes requested by a read, or -1
+	w    []byte // data sent to the device, or nil
+	r    []byte // data received from the device, or nil
+	err  string // encoded error, or ""
+}
+
+func (e entry) String() string {
+	var o strings.Builder
+	fmt.Fprintf(&o, "%s addr=0x%02x", e.op, e.addr)
+	if e.reg >= 0 {
+		fmt.Fprintf(&o, " reg=0x%02x", e.reg)
+	}
+	if e.n >= 0 {
+		fmt.Fprintf(&o, " n=%d", e.n)
+	}
+	if e.w != nil {
+		fmt.Fprintf(&o, " w=%x", e.w)
+	}
+	if e.r != nil {
+		fmt.Fprintf(&o, " r=%x", e.r)
+	}
+	if e.err != "" {
+		fmt.Fprintf(&o, " err=%s", e.err)
+	}
+	return o.String()
+}
+
+// match reports whether the request req matches the recorded transaction e.
+func (e entry) match(req entry) bool {
+	return e.op == req.op && e.addr == req.addr && e.reg == req.reg &&
+		e.n == req.n && bytes.Equal(e.w, req.w)
+}
+
+func parseEntry(line string) (entry, error) {
+	e := entry{reg: -1, n: -1}
+	if i := strings.Index(line, " err="); i >= 0 {
+		e.err = line[i+len(" err="):]
+		line = line[:i]
+	}
+	toks := strings.Fields(line)
+	e.op = toks[0]
+	hasAddr := false
+	for _, tok := range toks[1:] {
+		k, v, ok := strings.Cut(tok, "=")
+		if !ok {
+			return e, fmt.Errorf("invalid field %q", tok)
+		}
+		var err error
+		switch k {
+		case "addr":
+			var u uint64
+			u, err = strconv.ParseUint(v, 0, 8)
+			e.addr = uint8(u)
+			hasAddr = true
+		case "reg":
+			var u uint64
+			u, err = strconv.ParseUint(v, 0, 8)
+			e.reg = int(u)
+		case "n":
+			e.n, err = strconv.Atoi(v)
+		case "w":
+			e.w, err = hex.DecodeString(v)
+		case "r":
+			e.r, err = hex.DecodeString(v)
+		default:
+			err = fmt.Errorf("unknown field")
+		}
+		if err != nil {
+			return e, fmt.Errorf("invalid field %q: %w", tok, err)
+		}
+	}
+	if !hasAddr {
+		return e, fmt.Errorf("missing addr field")
+	}
+	return e, nil
+}
+
+// errnos lists the errors recorded by name.
+var errnos = []struct {
+	name  string
+	errno syscall.Errno
+}{
+	{"EAGAIN", syscall.EAGAIN},
+	{"EBADMSG", syscall.EBADMSG},
+	{"EBUSY", syscall.EBUSY},
+	{"EINVAL", syscall.EINVAL},
+	{"EIO", syscall.EIO},
+	{"ENODEV", syscall.ENODEV},
+	{"ENXIO", syscall.ENXIO},
+	{"EOPNOTSUPP", syscall.EOPNOTSUPP},
+	{"EPROTO", syscall.EPROTO},
+	{"EREMOTEIO", syscall.EREMOTEIO},
+	{"ETIMEDOUT", syscall.ETIMEDOUT},
+}
+
+// kinds lists the well-known errors recorded by kind.
+var kinds = []struct {
+	name string
+	err  error
+}{
+	{"unsupported", smbus.ErrUnsupported},
+	{"nack", smbus.ErrNACK},
+	{"timeout", smbus.ErrTimeout},
+	{"busy", smbus.ErrBusBusy},
+	{"canceled", context.Canceled},
+	{"deadline-exceeded", context.DeadlineExceeded},
+}
+
+func encodeErr(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, smbus.ErrUnsupported) {
+		return "unsupported"
+	}
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		for _, v := range errnos {
+			if v.errno == errno {
+				return v.name
+			}
+		}
+		return fmt.Sprintf("errno:%d", uintptr(errno))
+	}
+	var names []string
+	for _, v := range kinds {
+		if errors.Is(err, v.err) {
+			names = append(names, v.name)
+		}
+	}
+	if len(names) > 0 {
+		return strings.Join(names, "+")
+	}
+	return strconv.Quote(err.Error())
+}
+
+// decodeErr returns the error encoded in e.
+// Errnos are wrapped into a smbus.Error, as a smbus.Conn does.
+func decodeErr(e entry) error {
+	if e.err == "" {
+		return nil
+	}
+	if err := decodeKinds(e.err); err != nil {
+		return err
+	}
+	if msg, err := strconv.Unquote(e.err); err == nil {
+		return errors.New(msg)
+	}
+	var errno syscall.Errno
+	if v, ok := strings.CutPrefix(e.err, "errno:"); ok {
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return errors.New(e.err)
+		}
+		errno = syscall.Errno(n)
+	} else {
+		for _, v := range errnos {
+			if v.name == e.err {
+				errno = v.errno
+			}
+		}
+		if errno == 0 {
+			return errors.New(e.err)
+		}
+	}
+	return &smbus.Error{Op: e.op, Bus: -1, Addr: smbus.Addr7(e.addr), Reg: e.reg, Err: errno}
+}
+
+// decodeKinds returns the error matching the '+'-separated list of kinds
+// s, or nil if s is not such a list.
+func decodeKinds(s string) error {
+	var err error
+	for _, name := range strings.Split(s, "+") {
+		var kind error
+		for _, v := range kinds {
+			if v.name == name {
+				kind = v.err
+			}
+		}
+		switch {
+		case kind == nil:
+			return nil
+		case err == nil:
+			err = kind
+		default:
+			err = fmt.Errorf("%w: %w", err, kind)
+		}
+	}
+	return err
+}
+
+// Recorder is a bus recording all the transactions performed through it
+// to a transcript, before forwarding them to an underlying bus, e.g. a
+// smbus.Conn connected to real hardware.
+//
+// Transactions are recorded in the order they complete.
+// Recorder honours the locking and context support of the underlying bus.
+type Recorder struct {
+	bus smbus.Bus
+	rec *recording
+}
+
+// recording is the state of a Recorder, shared by its views.
+type recording struct {
+	mu     sync.Mutex
+	w      io.Writer
+	header bool
+	addr   uint8 // address targeted by Read and Write
+	err    error // first error while writing the transcript
+}
+
+// NewRecorder returns a bus forwarding all transactions to b and recording
+// them to w, in the transcript format.
+func NewRecorder(b smbus.Bus, w io.Writer) *Recorder {
+	return &Recorder{bus: b, rec: &recording{w: w}}
+}
+
+// Err returns the first error encountered while writing the transcript.
+func (r *Recorder) Err() error {
+	r.rec.mu.Lock()
+	defer r.rec.mu.Unlock()
+	return r.rec.err
+}
+
+func (r *Recorder) record(e entry, err error) {
+	e.err = encodeErr(err)
+
+	rec := r.rec
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.err != nil {
+		return
+	}
+	if !rec.header {
+		_, rec.err = io.WriteString(rec.w, transcriptHeader+"\n")
+		rec.header = true
+		if rec.err != nil {
+			return
+		}
+	}
+	_, rec.err = io.WriteString(rec.w, e.String()+"\n")
+}
+
+// target records addr as the address targeted by Read and Write.
+func (r *Recorder) target(addr uint8) {
+	r.rec.mu.Lock()
+	defer r.rec.mu.Unlock()
+	r.rec.addr = addr
+}
+
+func (r *Recorder) current() uint8 {
+	r.rec.mu.Lock()
+	defer r.rec.mu.Unlock()
+	return r.rec.addr
+}
+
+// WithLock calls f with a recording view of the underlying bus, holding
+// its lock.
+func (r *Recorder) WithLock(f func(b smbus.Bus) error) error {
+	return smbus.WithLock(r.bus, func(b smbus.Bus) error {
+		return f(&Recorder{bus: b, rec: r.rec})
+	})
+}
+
+// WithContext returns a recording view of the underlying bus, honouring ctx.
+func (r *Recorder) WithContext(ctx context.Context) smbus.Bus {
+	return &Recorder{bus: smbus.WithContext(ctx, r.bus), rec: r.rec}
+}
+
+func (r *Recorder) SetAddr(addr uint8) error {
+	r.target(addr)
+	err := r.bus.SetAddr(addr)
+	r.record(entry{op: "set-addr", addr: addr, reg: -1, n: -1}, err)
+	return err
+}
+
+func (r *Recorder) Read(p []byte) (int, error) {
+	n, err := r.bus.Read(p)
+	r.record(entry{op: "read", addr: r.current(), reg: -1, n: len(p), r: p[:n]}, err)
+	return n, err
+}
+
+func (r *Recorder) Write(p []byte) (int, error) {
+	n, err := r.bus.Write(p)
+	r.record(entry{op: "write", addr: r.current(), reg: -1, n: -1, w: p}, err)
+	return n, err
+}
+
+func (r *Recorder) Close() error {
+	err := r.bus.Close()
+	r.record(entry{op: "close", addr: r.current(), reg: -1, n: -1}, err)
+	return err
+}
+
+func (r *Recorder) ReadReg(addr, reg uint8) (uint8, error) {
+	r.target(addr)
+	v, err := r.bus.ReadReg(addr, reg)
+	e := entry{op: "read-reg", addr: addr, reg: int(reg), n: -1}
+	if err == nil {
+		e.r = []byte{v}
+	}
+	r.record(e, err)
+	return v, err
+}
+
+func (r *Recorder) WriteReg(addr, reg, v uint8) error {
+	r.target(addr)
+	err := r.bus.WriteReg(addr, reg, v)
+	r.record(entry{op: "write-reg", addr: addr, reg: int(reg), n: -1, w: []byte{v}}, err)
+	return err
+}
+
+func (r *Recorder) ReadWord(addr, reg uint8) (uint16, error) {
+	r.target(addr)
+	v, err := r.bus.ReadWord(addr, reg)
+	e := entry{op: "read-word", addr: addr, reg: int(reg), n: -1}
+	if err == nil {
+		e.r = []byte{uint8(v), uint8(v >> 8)}
+	}
+	r.record(e, err)
+	return v, err
+}
+
+func (r *Recorder) WriteWord(addr, reg uint8, v uint16) error {
+	r.target(addr)
+	err := r.bus.WriteWord(addr, reg, v)
+	r.record(entry{op: "write-word", addr: addr, reg: int(reg), n: -1, w: []byte{uint8(v), uint8(v >> 8)}}, err)
+	return err
+}
+
+func (r *Recorder) ReadBlockData(addr, reg uint8, buf []byte) error {
+	r.target(addr)
+	err := r.bus.ReadBlockData(addr, reg, buf)
+	e := entry{op: "read-block-data", addr: addr, reg: int(reg), n: len(buf)}
+	if err == nil {
+		e.r = buf
+	}
+	r.record(e, err)
+	return err
+}
+
+func (r *Recorder) WriteBlockData(addr, reg uint8, buf []byte) error {
+	r.target(addr)
+	err := r.bus.WriteBlockData(addr, reg, buf)
+	r.record(entry{op: "write-block-data", addr: addr, reg: int(reg), n: -1, w: buf}, err)
+	return err
+}
+
+// DeviationError describes a transaction deviating from a transcript.
+type DeviationError struct {
+	Line int    // line of the expected transaction, or 0 past the end of the transcript
+	Want string // expected transaction, as recorded
+	Got  string // performed transaction
+}
+
+func (e *DeviationError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("smbustest: unexpected transaction past the end of the transcript: %s", e.Got)
+	}
+	return fmt.Sprintf("smbustest: transaction deviates from transcript line %d:\ngot:  %s\nwant: %s", e.Line, e.Got, e.Want)
+}
+
+// Replay is a bus answering the transactions recorded in a transcript.
+//
+// Transactions must be performed in the recorded order. Replay answers
+// each matching transaction with the recorded data and error.
+// A transaction deviating from the transcript fails with a
+// *DeviationError and does not consume the transcript.
+type Replay struct {
+	mu      sync.Mutex
+	entries []entry
+	lines   []int
+	pos     int
+	err     error // first deviation
+}
+
+// NewReplay returns a bus replaying the transcript read from r.
+func NewReplay(r io.Reader) (*Replay, error) {
+	var (
+		rp     Replay
+		sc     = bufio.NewScanner(r)
+		line   = 0
+		header = false
+	)
+	for sc.Scan() {
+		line++
+		txt := strings.TrimSpace(sc.Text())
+		if !header {
+			if txt != transcriptHeader {
+				return nil, fmt.Errorf("smbustest: invalid transcript header %q", txt)
+			}
+			header = true
+			continue
+		}
+		if txt == "" || strings.HasPrefix(txt, "#") {
+			continue
+		}
+		e, err := parseEntry(txt)
+		if err != nil {
+			return nil, fmt.Errorf("smbustest: invalid transcript line %d: %w", line, err)
+		}
+		rp.entries = append(rp.entries, e)
+		rp.lines = append(rp.lines, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("smbustest: could not read transcript: %w", err)
+	}
+	if !header {
+		return nil, fmt.Errorf("smbustest: empty transcript")
+	}
+	return &rp, nil
+}
+
+// Verify returns the first deviation from the transcript, if any, or an
+// error if some recorded transactions have not been replayed.
+func (rp *Replay) Verify() error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if rp.err != nil {
+		return rp.err
+	}
+	if n := len(rp.entries) - rp.pos; n > 0 {
+		return fmt.Errorf("smbustest: %d transaction(s) not replayed, starting at transcript line %d: %s",
+			n, rp.lines[rp.pos], rp.entries[rp.pos],
+		)
+	}
+	return nil
+}
+
+// next consumes the recorded transaction matching the request req.
+func (rp *Replay) next(req entry) (entry, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	var err *DeviationError
+	switch {
+	case rp.pos >= len(rp.entries):
+		err = &DeviationError{Got: req.String()}
+	case !rp.entries[rp.pos].match(req):
+		err = &DeviationError{
+			Line: rp.lines[rp.pos],
+			Want: rp.entries[rp.pos].String(),
+			Got:  req.String(),
+		}
+	}
+	if err != nil {
+		if rp.err == nil {
+			rp.err = err
+		}
+		return entry{}, err
+	}
+	e := rp.entries[rp.pos]
+	rp.pos++
+	return e, decodeErr(e)
+}
+
+func (rp *Replay) current() uint8 {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	for i := rp.pos - 1; i >= 0; i-- {
+		if e := rp.entries[i]; e.op != "read" && e.op != "write" && e.op != "close" {
+			return e.addr
+		}
+	}
+	return 0
+}
+
+func (rp *Replay) SetAddr(addr uint8) error {
+	_, err := rp.next(entry{op: "set-addr", addr: addr, reg: -1, n: -1})
+	return err
+}
+
+func (rp *Replay) Read(p []byte) (int, error) {
+	e, err := rp.next(entry{op: "read", addr: rp.current(), reg: -1, n: len(p)})
+	return copy(p, e.r), err
+}
+
+func (rp *Replay) Write(p []byte) (int, error) {
+	_, err := rp.next(entry{op: "write", addr: rp.current(), reg: -1, n: -1, w: p})
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (rp *Replay) Close() error {
+	_, err := rp.next(entry{op: "close", addr: rp.current(), reg: -1, n: -1})
+	return err
+}
+
+func (rp *Replay) ReadReg(addr, reg uint8) (uint8, error) {
+	e, err := rp.next(entry{op: "read-reg", addr: addr, reg: int(reg), n: -1})
+	if err != nil {
+		return 0, err
+	}
+	if len(e.r) != 1 {
+		return 0, fmt.Errorf("smbustest: invalid read-reg data %x", e.r)
+	}
+	return e.r[0], nil
+}
+
+func (rp *Replay) WriteReg(addr, reg, v uint8) error {
+	_, err := rp.next(entry{op: "write-reg", addr: addr, reg: int(reg), n: -1, w: []byte{v}})
+	return err
+}
+
+func (rp *Replay) ReadWord(addr, reg uint8) (uint16, error) {
+	e, err := rp.next(entry{op: "read-word", addr: addr, reg: int(reg), n: -1})
+	if err != nil {
+		return 0, err
+	}
+	if len(e.r) != 2 {
+		return 0, fmt.Errorf("smbustest: invalid read-word data %x", e.r)
+	}
+	return uint16(e.r[0]) | uint16(e.r[1])<<8, nil
+}
+
+func (rp *Replay) WriteWord(addr, reg uint8, v uint16) error {
+	_, err := rp.next(entry{op: "write-word", addr: addr, reg: int(reg), n: -1, w: []byte{uint8(v), uint8(v >> 8)}})
+	return err
+}
+
+func (rp *Replay) ReadBlockData(addr, reg uint8, buf []byte) error {
+	e, err := rp.next(entry{op: "read-block-data", addr: addr, reg: int(reg), n: len(buf)})
+	if err != nil {
+		return err
+	}
+	copy(buf, e.r)
+	return nil
+}
+
+func (rp *Replay) WriteBlockData(addr, reg uint8, buf []byte) error {
+	_, err := rp.next(entry{op: "write-block-data", addr: addr, reg: int(reg), n: -1, w: buf})
+	return err
+}
+
+var (
+	_ smbus.Bus    = (*Recorder)(nil)
+	_ smbus.Locker = (*Recorder)(nil)
+	_ smbus.Bus    = (*Replay)(nil)
+)
@@ -0,0 +1,202 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbustest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-daq/smbus"
+	"github.com/go-daq/smbus/sensor/hts221"
+	"github.com/go-daq/smbus/smbustest"
+)
+
+func newHTS221() *smbustest.Bus {
+	bus := smbustest.New()
+	dev := &smbustest.Device{RegMask: 0x7f}
+	dev.MustSet(0x27, 0x03)
+	dev.MustSet(0x28, 0xf4, 0x01)
+	dev.MustSet(0x2a, 0xf4, 0x01)
+	dev.MustSet(0x30, 0x40, 0x80)
+	dev.MustSet(0x32, 0x50, 0xa0)
+	dev.MustSet(0x3a, 0xe8, 0x03)
+	dev.MustSet(0x3e, 0xe8, 0x03)
+	bus.Attach(hts221.SlaveAddr, dev)
+	return bus
+}
+
+func TestRecordReplay(t *testing.T) {
+	o := new(bytes.Buffer)
+	rec := smbustest.NewRecorder(newHTS221(), o)
+
+	sensor, err := hts221.Open(rec, hts221.SlaveAddr)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	h1, t1, err := sensor.Sample()
+	if err != nil {
+		t.Fatalf("sample error: %v", err)
+	}
+	_, err = rec.ReadReg(0x42, 0x00)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	_, err = rec.Write([]byte{0x0f})
+	if err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	err = rec.Close()
+	if err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+	if err := rec.Err(); err != nil {
+		t.Fatalf("record error: %v", err)
+	}
+
+	transcript := o.String()
+	for _, want := range []string{
+		"# smbus transcript v1\n",
+		"set-addr addr=0x5f\n",
+		"read-word addr=0x5f reg=0xa8 r=f401\n",
+		"read-reg addr=0x42 reg=0x00 err=ENXIO\n",
+		"write addr=0x42 w=0f\n",
+	} {
+		if !strings.Contains(transcript, want) {
+			t.Fatalf("missing %q in transcript:\n%s", want, transcript)
+		}
+	}
+
+	rp, err := smbustest.NewReplay(strings.NewReader(transcript))
+	if err != nil {
+		t.Fatalf("replay error: %v", err)
+	}
+	sensor, err = hts221.Open(rp, hts221.SlaveAddr)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	h2, t2, err := sensor.Sample()
+	if err != nil {
+		t.Fatalf("sample error: %v", err)
+	}
+	if h1 != h2 || t1 != t2 {
+		t.Fatalf("invalid replayed sample: got=(%v, %v), want=(%v, %v)", h2, t2, h1, t1)
+	}
+
+	_, err = rp.ReadReg(0x42, 0x00)
+	if !errors.Is(err, smbus.ErrNACK) {
+		t.Fatalf("invalid replayed error: got=%v, want=%v", err, smbus.ErrNACK)
+	}
+	if err := rp.Verify(); err == nil {
+		t.Fatalf("expected an error for transactions not replayed")
+	}
+
+	_, err = rp.Write([]byte{0x0e})
+	var dev *smbustest.DeviationError
+	if !errors.As(err, &dev) {
+		t.Fatalf("expected a deviation error, got %v", err)
+	}
+	if got, want := dev.Want, "write addr=0x42 w=0f"; got != want {
+		t.Fatalf("invalid deviation:\ngot= %s\nwant=%s", got, want)
+	}
+
+	_, err = rp.Write([]byte{0x0f})
+	if err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	err = rp.Close()
+	if err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+	if err := rp.Verify(); !errors.As(err, &dev) {
+		t.Fatalf("verify should report the first deviation, got %v", err)
+	}
+
+	err = rp.Close()
+	if !errors.As(err, &dev) || dev.Line != 0 {
+		t.Fatalf("expected a deviation past the end of the transcript, got %v", err)
+	}
+}
+
+func TestRecordReplayErrors(t *testing.T) {
+	errs := []error{
+		smbus.ErrNACK,
+		smbus.ErrTimeout,
+		smbus.ErrBusBusy,
+		smbus.ErrUnsupported,
+		context.Canceled,
+		context.DeadlineExceeded,
+		fmt.Errorf("%w: %w", smbus.ErrTimeout, context.DeadlineExceeded),
+		errors.New("boom"),
+	}
+	bus := smbustest.New()
+	bus.Attach(0x76, &smbustest.Device{
+		ReadHook: func(dev *smbustest.Device, reg uint8) error {
+			return errs[reg]
+		},
+	})
+
+	o := new(bytes.Buffer)
+	rec := smbustest.NewRecorder(bus, o)
+	for i := range errs {
+		_, err := rec.ReadReg(0x76, uint8(i))
+		if err == nil {
+			t.Fatalf("expected an error")
+		}
+	}
+	if err := rec.Err(); err != nil {
+		t.Fatalf("record error: %v", err)
+	}
+	for _, want := range []string{
+		"reg=0x00 err=nack\n",
+		"reg=0x04 err=canceled\n",
+		"reg=0x06 err=timeout+deadline-exceeded\n",
+		"reg=0x07 err=\"boom\"\n",
+	} {
+		if !strings.Contains(o.String(), want) {
+			t.Fatalf("missing %q in transcript:\n%s", want, o.String())
+		}
+	}
+
+	rp, err := smbustest.NewReplay(o)
+	if err != nil {
+		t.Fatalf("replay error: %v", err)
+	}
+	for i, want := range errs {
+		_, err := rp.ReadReg(0x76, uint8(i))
+		if err == nil || err.Error() != want.Error() {
+			t.Fatalf("invalid replayed error #%d: got=%v, want=%v", i, err, want)
+		}
+		for _, sentinel := range []error{
+			smbus.ErrNACK, smbus.ErrTimeout, smbus.ErrBusBusy, smbus.ErrUnsupported,
+			context.Canceled, context.DeadlineExceeded,
+		} {
+			if got, want := errors.Is(err, sentinel), errors.Is(want, sentinel); got != want {
+				t.Fatalf("invalid replayed error #%d: errors.Is(%v, %v): got=%v, want=%v", i, err, sentinel, got, want)
+			}
+		}
+	}
+	if err := rp.Verify(); err != nil {
+		t.Fatalf("verify error: %v", err)
+	}
+}
+
+func TestReplayInvalid(t *testing.T) {
+	for _, tc := range []string{
+		"",
+		"read-reg addr=0x42 reg=0x00\n",
+		"# smbus transcript v1\nread-reg reg=0x00\n",
+		"# smbus transcript v1\nread-reg addr=0x42 reg=0x00 r=zz\n",
+		"# smbus transcript v1\nread-reg addr=0x42 foo=bar\n",
+	} {
+		_, err := smbustest.NewReplay(strings.NewReader(tc))
+		if err == nil {
+			t.Errorf("expected an error for transcript %q", tc)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// Event describes a transaction performed on the bus.
+type Event struct {
+	Op       string        // operation, e.g. "read-reg"
+	Bus      int           // i2c bus number, or -1 if unknown
+	Addr     Addr          // address of the device
+	Reg      int           // designated register, or -1 if not applicable
+	Write    []byte        // data sent to the device
+	Read     []byte        // data received from the device
+	Time     time.Time     // start of the transaction
+	Duration time.Duration // duration of the transaction, including retries
+	Err      error         // error of the transaction, if any
+}
+
+// String returns a one-line description of the event, with the data
+// exchanged with the device as hexadecimal bytes.
+func (ev Event) String() string {
+	var o strings.Builder
+	o.WriteString(ev.Op)
+	if ev.Bus >= 0 {
+		fmt.Fprintf(&o, " bus=%d", ev.Bus)
+	}
+	fmt.Fprintf(&o, " addr=%v", ev.Addr)
+	if ev.Reg >= 0 {
+		fmt.Fprintf(&o, " reg=0x%02x", ev.Reg)
+	}
+	if len(ev.Write) > 0 {
+		fmt.Fprintf(&o, " w=[% x]", ev.Write)
+	}
+	if len(ev.Read) > 0 {
+		fmt.Fprintf(&o, " r=[% x]", ev.Read)
+	}
+	fmt.Fprintf(&o, " (%v)", ev.Duration)
+	if ev.Err != nil {
+		fmt.Fprintf(&o, ": %v", ev.Err)
+	}
+	return o.String()
+}
+
+// Tracer receives the transactions performed by a Conn.
+//
+// Trace is called after each transaction, with the lock of the Conn held:
+// Trace must not use the Conn. Trace must not retain ev.Write nor ev.Read.
+type Tracer interface {
+	Trace(ev Event)
+}
+
+// TracerFunc is an adapter to use ordinary functions as tracers.
+type TracerFunc func(ev Event)
+
+// Trace calls f(ev).
+func (f TracerFunc) Trace(ev Event) { f(ev) }
+
+// Trace configures a tracer receiving all the transactions of the connection.
+// Tracing is disabled by default.
+func Trace(t Tracer) func(cfg *config) {
+	return func(cfg *config) {
+		cfg.Tracer = t
+	}
+}
+
+// SetTracer sets the tracer receiving all the transactions of the
+// connection. A nil tracer disables tracing.
+func (c *Conn) SetTracer(t Tracer) {
+	c.lock()
+	defer c.unlock()
+
+	c.tracer = t
+}
+
+// NewSlogTracer returns a tracer logging transactions to l.
+// Successful transactions are logged at level; failed transactions are
+// logged at slog.LevelWarn, or level if higher.
+func NewSlogTracer(l *slog.Logger, level slog.Level) Tracer {
+	return &slogTracer{l: l, level: level}
+}
+
+type slogTracer struct {
+	l     *slog.Logger
+	level slog.Level
+}
+
+func (t *slogTracer) Trace(ev Event) {
+	level := t.level
+	if ev.Err != nil && level < slog.LevelWarn {
+		level = slog.LevelWarn
+	}
+	ctx := context.Background()
+	if !t.l.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := make([]slog.Attr, 0, 8)
+	attrs = append(attrs, slog.String("op", ev.Op))
+	if ev.Bus >= 0 {
+		attrs = append(attrs, slog.Int("bus", ev.Bus))
+	}
+	attrs = append(attrs, slog.String("addr", ev.Addr.String()))
+	if ev.Reg >= 0 {
+		attrs = append(attrs, slog.String("reg", fmt.Sprintf("0x%02x", ev.Reg)))
+	}
+	if len(ev.Write) > 0 {
+		attrs = append(attrs, slog.String("write", hex.EncodeToString(ev.Write)))
+	}
+	if len(ev.Read) > 0 {
+		attrs = append(attrs, slog.String("read", hex.EncodeToString(ev.Read)))
+	}
+	attrs = append(attrs, slog.Duration("duration", ev.Duration))
+	if ev.Err != nil {
+		attrs = append(attrs, slog.Any("err", ev.Err))
+	}
+	t.l.LogAttrs(ctx, level, "smbus transaction", attrs...)
+}
+
+// NewTextTracer returns a tracer writing one line per transaction to w,
+// as returned by Event.String, prefixed with the time of the transaction.
+// Data larger than 16 bytes is also written as an indented hex dump,
+// as returned by encoding/hex.Dump.
+//
+// The returned tracer is safe for concurrent use.
+func NewTextTracer(w io.Writer) Tracer {
+	return &textTracer{w: w}
+}
+
+type textTracer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (t *textTracer) Trace(ev Event) {
+	var o strings.Builder
+	o.WriteString(ev.Time.Format("15:04:05.000000 "))
+	o.WriteString(ev.String())
+	o.WriteString("\n")
+	for _, p := range [][]byte{ev.Write, ev.Read} {
+		if len(p) <= 16 {
+			continue
+		}
+		for _, line := range strings.SplitAfter(strings.TrimSuffix(hex.Dump(p), "\n"), "\n") {
+			o.WriteString("\t")
+			o.WriteString(line)
+		}
+		o.WriteString("\n")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	io.WriteString(t.w, o.String())
+}
+
+// begin returns a new event for the operation op, if tracing is enabled.
+// begin returns nil otherwise.
+func (c *Conn) begin(op string, addr Addr, reg int) *Event {
+	if c.tracer == nil {
+		return nil
+	}
+	return &Event{Op: op, Bus: c.bus, Addr: addr, Reg: reg, Time: time.Now()}
+}
+
+// end completes ev and sends it to the tracer.
+func (c *Conn) end(ev *Event, err error) {
+	ev.Duration = time.Since(ev.Time)
+	ev.Err = err
+	c.tracer.Trace(*ev)
+}
+
+// payload returns a copy of the data block of the SMBus command cmd,
+// in bus order.
+func payload(cmd *i2cCmd) []byte {
+	if cmd.ptr == nil {
+		return nil
+	}
+	switch cmd.len {
+	case i2cSMBusByte, i2cSMBusByteData:
+		return []byte{*(*uint8)(cmd.ptr)}
+	case i2cSMBusWordData, i2cSMBusProcCall:
+		v := *(*uint16)(cmd.ptr)
+		return []byte{byte(v), byte(v >> 8)}
+	case i2cSMBusBlockData, i2cSMBusBlockProcCall, i2cSMBusI2CBlockData:
+		data := unsafe.Slice((*byte)(cmd.ptr), i2cSMBusBlockMax+2)
+		n := int(data[0])
+		if n > int(i2cSMBusBlockMax) {
+			n = int(i2cSMBusBlockMax)
+		}
+		return append([]byte(nil), data[1:1+n]...)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2017 The go-daq Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package smbus_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/go-daq/smbus"
+)
+
+func TestTracer(t *testing.T) {
+	c, bus := newSimConn(0x76)
+	defer c.Close()
+	bus.Device(0x76).MustSet(0xd0, 0x60, 0x01, 0x02)
+
+	var evs []smbus.Event
+	c.SetTracer(smbus.TracerFunc(func(ev smbus.Event) {
+		evs = append(evs, ev)
+	}))
+
+	_, err := c.ReadReg(0x76, 0xd0)
+	if err != nil {
+		t.Fatalf("read-reg error: %v", err)
+	}
+	err = c.WriteWord(0x76, 0xf4, 0x1234)
+	if err != nil {
+		t.Fatalf("write-word error: %v", err)
+	}
+	err = c.ReadBlockData(0x76, 0xd0, make([]byte, 3))
+	if err != nil {
+		t.Fatalf("read-block-data error: %v", err)
+	}
+	err = c.Tx(0x76, []byte{0xd1}, make([]byte, 2))
+	if err != nil {
+		t.Fatalf("tx error: %v", err)
+	}
+	_, err = c.ReadReg(0x42, 0x00)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	c.SetTracer(nil)
+	_, err = c.ReadReg(0x76, 0xd0)
+	if err != nil {
+		t.Fatalf("read-reg error: %v", err)
+	}
+
+	want := []string{
+		"read-reg addr=0x76 reg=0xd0 r=[60]",
+		"write-word addr=0x76 reg=0xf4 w=[34 12]",
+		"read-i2c-block-data addr=0x76 reg=0xd0 r=[60 01 02]",
+		"transfer addr=0x76 w=[d1] r=[01 02]",
+		"read-reg addr=0x42 reg=0x00",
+	}
+	if len(evs) != len(want) {
+		t.Fatalf("invalid number of events: got=%d, want=%d", len(evs), len(want))
+	}
+	for i, ev := range evs {
+		got := ev.String()
+		if !strings.HasPrefix(got, want[i]+" (") {
+			t.Errorf("invalid event #%d:\ngot= %s\nwant=%s", i, got, want[i])
+		}
+	}
+	if !errors.Is(evs[4].Err, smbus.ErrNACK) {
+		t.Fatalf("invalid event error: %v", evs[4].Err)
+	}
+}
+
+func TestTextTracer(t *testing.T) {
+	c, bus := newSimConn(0x76)
+	defer c.Close()
+	bus.Device(0x76).MustSet(0x88, []byte("0123456789abcdefghij")...)
+
+	o := new(bytes.Buffer)
+	c.SetTracer(smbus.NewTextTracer(o))
+
+	err := c.ReadBlockData(0x76, 0x88, make([]byte, 20))
+	if err != nil {
+		t.Fatalf("read-block-data error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(o.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("invalid number of lines: got=%d, want=3\n%s", len(lines), o.String())
+	}
+	if !strings.Contains(lines[0], "read-i2c-block-data addr=0x76 reg=0x88 r=[30 31 32") {
+		t.Fatalf("invalid summary line: %q", lines[0])
+	}
+	if want := "\t00000000  30 31 32 33 34 35 36 37  38 39 61 62 63 64 65 66  |0123456789abcdef|"; lines[1] != want {
+		t.Fatalf("invalid hex dump:\ngot= %q\nwant=%q", lines[1], want)
+	}
+}
+
+func TestSlogTracer(t *testing.T) {
+	c, _ := newSimConn(0x76)
+	defer c.Close()
+
+	o := new(bytes.Buffer)
+	l := slog.New(slog.NewTextHandler(o, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	c.SetTracer(smbus.NewSlogTracer(l, slog.LevelDebug))
+
+	err := c.WriteReg(0x76, 0xf4, 0x3f)
+	if err != nil {
+		t.Fatalf("write-reg error: %v", err)
+	}
+	if o.Len() != 0 {
+		t.Fatalf("debug event should not be logged:\n%s", o.String())
+	}
+
+	_, err = c.ReadReg(0x42, 0x00)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	got := o.String()
+	for _, want := range []string{"level=WARN", "op=read-reg", "addr=0x42", "reg=0x00", "err="} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q in log:\n%s", want, got)
+		}
+	}
+}